@@ -5,6 +5,7 @@ go 1.24.5
 require (
 	github.com/rs/zerolog v1.34.0
 	go.mau.fi/util v0.9.2
+	golang.org/x/image v0.30.0
 	maunium.net/go/mautrix v0.25.2
 )
 
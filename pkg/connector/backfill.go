@@ -0,0 +1,107 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+var _ bridgev2.BackfillingNetworkAPI = (*HostexNetworkAPI)(nil)
+
+// FetchMessages returns a batch of messages for backfilling a portal. The conversation details
+// endpoint returns the whole message history at once, so batches are cut from it locally:
+// forward batches are the newest messages after the anchor, and backward batches page through
+// older messages using the timestamp of the oldest message returned so far as the cursor.
+func (hn *HostexNetworkAPI) FetchMessages(ctx context.Context, params bridgev2.FetchMessagesParams) (*bridgev2.FetchMessagesResponse, error) {
+	conversationID := string(params.Portal.ID)
+	details, ok := params.BundledData.(*hostexapi.ConversationDetails)
+	if !ok || details == nil || details.ID != conversationID {
+		var err error
+		details, err = hn.client.GetConversationDetails(ctx, conversationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get conversation details: %w", err)
+		}
+	}
+
+	// Messages are in reverse chronological order
+	var batch []hostexapi.Message
+	hasMore := false
+	if params.Forward {
+		for _, msg := range details.Messages {
+			if params.AnchorMessage != nil && !msg.CreatedAt.After(params.AnchorMessage.Timestamp) {
+				break
+			} else if len(batch) >= params.Count {
+				break
+			}
+			batch = append(batch, msg)
+		}
+	} else {
+		before := time.Now().Add(time.Minute)
+		if params.Cursor != "" {
+			unixNano, err := strconv.ParseInt(string(params.Cursor), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid backfill cursor %q: %w", params.Cursor, err)
+			}
+			before = time.Unix(0, unixNano)
+		} else if params.AnchorMessage != nil {
+			before = params.AnchorMessage.Timestamp
+		}
+		for _, msg := range details.Messages {
+			if !msg.CreatedAt.Before(before) {
+				continue
+			} else if len(batch) >= params.Count {
+				hasMore = true
+				break
+			}
+			batch = append(batch, msg)
+		}
+	}
+	slices.Reverse(batch)
+
+	resp := &bridgev2.FetchMessagesResponse{
+		Messages: make([]*bridgev2.BackfillMessage, 0, len(batch)),
+		HasMore:  hasMore,
+		Forward:  params.Forward,
+		// Messages may have been bridged as live events already
+		AggressiveDeduplication: true,
+		// Historical messages in a new room shouldn't show up as unread
+		MarkRead: params.AnchorMessage == nil,
+	}
+	if len(batch) > 0 {
+		resp.Cursor = networkid.PaginationCursor(strconv.FormatInt(batch[0].CreatedAt.UnixNano(), 10))
+	}
+	for _, msg := range batch {
+		if hostexapi.IsPendingMessageID(msg.ID) {
+			continue
+		}
+		sender := hn.messageSender(&msg, conversationID)
+		intent, ok := params.Portal.GetIntentFor(ctx, sender, hn.login, bridgev2.RemoteEventBackfill)
+		if !ok {
+			continue
+		}
+		converted, err := hn.convertMessage(ctx, params.Portal, intent, &msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert message %s: %w", msg.ID, err)
+		}
+		resp.Messages = append(resp.Messages, &bridgev2.BackfillMessage{
+			ConvertedMessage: converted,
+			Sender:           sender,
+			ID:               networkid.MessageID(msg.ID),
+			Timestamp:        msg.CreatedAt,
+		})
+	}
+	hn.br.Log.Debug().
+		Str("conversation_id", conversationID).
+		Bool("forward", params.Forward).
+		Int("message_count", len(resp.Messages)).
+		Bool("has_more", hasMore).
+		Msg("Fetched messages for backfill")
+	return resp, nil
+}
@@ -0,0 +1,107 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"maunium.net/go/mautrix/bridgev2/status"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+const (
+	// Number of failed poll cycles in a row before the login is reported as disconnected.
+	// A single failure is usually a blip that the next poll recovers from.
+	transientDisconnectThreshold = 3
+
+	errCodeInvalidToken status.BridgeStateErrorCode = "hostex-invalid-token"
+	errCodeRateLimited  status.BridgeStateErrorCode = "hostex-rate-limited"
+	errCodeUnreachable  status.BridgeStateErrorCode = "hostex-unreachable"
+)
+
+func init() {
+	status.BridgeStateHumanErrors.Update(status.BridgeStateErrorMap{
+		errCodeInvalidToken: "Hostex rejected the access token, please log in again",
+		errCodeRateLimited:  "Hostex is rate limiting requests",
+		errCodeUnreachable:  "Failed to reach the Hostex API",
+	})
+}
+
+// sendBridgeState sends a bridge state for the login unless it's identical to the last one sent.
+func (hn *HostexNetworkAPI) sendBridgeState(state status.BridgeState) {
+	if hn.login.BridgeState == nil {
+		hn.br.Log.Warn().Str("user_login", string(hn.login.ID)).Msg("BridgeState is nil, cannot send status")
+		return
+	}
+	hn.bridgeStateMu.Lock()
+	if hn.lastBridgeState == state.StateEvent && hn.lastBridgeStateError == state.Error {
+		hn.bridgeStateMu.Unlock()
+		return
+	}
+	hn.lastBridgeState = state.StateEvent
+	hn.lastBridgeStateError = state.Error
+	hn.bridgeStateMu.Unlock()
+
+	hn.br.Log.Info().
+		Str("user_login", string(hn.login.ID)).
+		Str("state_event", string(state.StateEvent)).
+		Str("error", string(state.Error)).
+		Msg("Sending bridge state")
+	hn.login.BridgeState.Send(state)
+}
+
+func (hn *HostexNetworkAPI) sendConnectedState() {
+	hn.sendBridgeState(status.BridgeState{
+		StateEvent: status.StateConnected,
+		Info: map[string]any{
+			"conn_timestamp": time.Now().Unix(),
+		},
+	})
+}
+
+// reportPollResult updates the bridge state after a poll cycle. Invalid tokens are reported
+// right away, other failures only once they've happened several times in a row, and the
+// login goes back to connected as soon as a poll succeeds again.
+func (hn *HostexNetworkAPI) reportPollResult(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		// Failures caused by the bridge shutting down aren't Hostex's fault
+		return
+	}
+
+	hn.bridgeStateMu.Lock()
+	if err == nil {
+		hn.pollFailures = 0
+	} else {
+		hn.pollFailures++
+	}
+	failures := hn.pollFailures
+	hn.bridgeStateMu.Unlock()
+
+	switch {
+	case err == nil:
+		hn.sendConnectedState()
+	case errors.Is(err, hostexapi.ErrUnauthorized):
+		hn.sendBridgeState(status.BridgeState{
+			StateEvent: status.StateBadCredentials,
+			Error:      errCodeInvalidToken,
+			Message:    fmt.Sprintf("Hostex rejected the access token: %v", err),
+		})
+	case failures >= transientDisconnectThreshold:
+		code := errCodeUnreachable
+		if errors.Is(err, hostexapi.ErrRateLimited) {
+			code = errCodeRateLimited
+		}
+		hn.sendBridgeState(status.BridgeState{
+			StateEvent: status.StateTransientDisconnect,
+			Error:      code,
+			Message:    fmt.Sprintf("Polling Hostex failed %d times in a row: %v", failures, err),
+			Info: map[string]any{
+				"consecutive_failures": failures,
+			},
+		})
+	default:
+		hn.br.Log.Debug().Err(err).Int("consecutive_failures", failures).Msg("Poll cycle failed")
+	}
+}
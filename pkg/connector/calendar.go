@@ -0,0 +1,159 @@
+package connector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/bridgev2/commands"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+const calendarUsage = "**Usage:**\n\n" +
+	"* `$cmdprefix calendar <property> [YYYY-MM]` - show the occupancy of a property\n" +
+	"* `$cmdprefix calendar block <property> <from> [to]` - block dates (YYYY-MM-DD)\n" +
+	"* `$cmdprefix calendar unblock <property> <from> [to]` - open blocked dates\n\n" +
+	"The property can be its ID or part of its name."
+
+// handleCalendarCommand handles the calendar command and its block and unblock subcommands.
+func (hc *HostexConnector) handleCalendarCommand(ce *commands.Event) {
+	if len(ce.Args) == 0 {
+		ce.Reply(calendarUsage)
+		return
+	}
+	hn := commandNetworkAPI(ce)
+	if hn == nil {
+		return
+	}
+	switch strings.ToLower(ce.Args[0]) {
+	case "block":
+		hn.handleCalendarUpdate(ce, ce.Args[1:], false)
+	case "unblock":
+		hn.handleCalendarUpdate(ce, ce.Args[1:], true)
+	default:
+		hn.handleCalendarView(ce, ce.Args)
+	}
+}
+
+func (hn *HostexNetworkAPI) handleCalendarView(ce *commands.Event, args []string) {
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if len(args) > 1 {
+		if parsed, err := time.Parse("2006-01", args[len(args)-1]); err == nil {
+			month = parsed
+			args = args[:len(args)-1]
+		}
+	}
+	property, err := hn.findProperty(ce.Ctx, strings.Join(args, " "))
+	if err != nil {
+		ce.Reply("❌ %v", err)
+		return
+	}
+
+	monthEnd := month.AddDate(0, 1, -1)
+	availability, err := hn.client.GetPropertyAvailability(ce.Ctx, property.ID, month, monthEnd)
+	if err != nil {
+		ce.Reply("❌ Failed to get availability: %v", err)
+		return
+	}
+	reservations, err := hn.client.FindReservations(ce.Ctx, hostexapi.ReservationListOptions{
+		PropertyID:   strconv.Itoa(property.ID),
+		Status:       hostexapi.ReservationStatusAccepted,
+		CheckInTo:    monthEnd,
+		CheckOutFrom: month,
+	})
+	if err != nil {
+		ce.Reply("❌ Failed to get reservations: %v", err)
+		return
+	}
+	ce.Reply(renderCalendar(property, month, availability, reservations))
+}
+
+func (hn *HostexNetworkAPI) handleCalendarUpdate(ce *commands.Event, args []string, available bool) {
+	args, from, to, err := parseDateRange(args)
+	if err != nil {
+		// Not using format arguments, as $cmdprefix is only replaced in the format string
+		ce.Reply("❌ " + err.Error() + "\n\n" + calendarUsage)
+		return
+	} else if len(args) == 0 {
+		ce.Reply(calendarUsage)
+		return
+	}
+	property, err := hn.findProperty(ce.Ctx, strings.Join(args, " "))
+	if err != nil {
+		ce.Reply("❌ %v", err)
+		return
+	}
+
+	if err = hn.client.UpdateAvailability(ce.Ctx, []int{property.ID}, from, to, available); err != nil {
+		ce.Reply("❌ Failed to update availability: %v", err)
+		return
+	}
+	hn.br.Log.Info().
+		Int("property_id", property.ID).
+		Str("from", from.Format(time.DateOnly)).
+		Str("to", to.Format(time.DateOnly)).
+		Bool("available", available).
+		Msg("Updated property availability from Matrix")
+	if available {
+		ce.Reply("✅ Opened **%s** from %s to %s", property.Title, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	} else {
+		ce.Reply("🚫 Blocked **%s** from %s to %s", property.Title, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+}
+
+// renderCalendar renders the occupancy of a property in a month as a markdown calendar grid,
+// followed by the reservations in that month.
+func renderCalendar(property *hostexapi.Property, month time.Time, availability []hostexapi.Availability, reservations []hostexapi.Reservation) string {
+	unavailable := make(map[string]bool)
+	for _, day := range availability {
+		if !day.Available {
+			unavailable[day.Date] = true
+		}
+	}
+	booked := make(map[string]bool)
+	for _, reservation := range reservations {
+		checkIn, err1 := time.Parse(time.DateOnly, reservation.CheckInDate)
+		checkOut, err2 := time.Parse(time.DateOnly, reservation.CheckOutDate)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		for day := checkIn; day.Before(checkOut); day = day.AddDate(0, 0, 1) {
+			booked[day.Format(time.DateOnly)] = true
+		}
+	}
+
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(&buf, "📅 **%s** - %s\n\n", property.Title, month.Format("January 2006"))
+	buf.WriteString("```\nMo  Tu  We  Th  Fr  Sa  Su\n")
+	// Weeks start on Monday
+	buf.WriteString(strings.Repeat("    ", (int(month.Weekday())+6)%7))
+	var days, bookedDays int
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		marker := ' '
+		if booked[date] {
+			marker = '*'
+			bookedDays++
+		} else if unavailable[date] {
+			marker = 'x'
+		}
+		days++
+		_, _ = fmt.Fprintf(&buf, "%2d%c ", day.Day(), marker)
+		if day.Weekday() == time.Sunday {
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString("\n```\n\n")
+	_, _ = fmt.Fprintf(&buf, "`*` booked, `x` blocked. Occupancy: %d of %d nights (%d%%)\n", bookedDays, days, bookedDays*100/days)
+
+	if len(reservations) > 0 {
+		buf.WriteString("\n**Reservations:**\n\n")
+		for _, reservation := range reservations {
+			_, _ = fmt.Fprintf(&buf, "* %s → %s: %s (`%s`)\n", reservation.CheckInDate, reservation.CheckOutDate, reservation.GuestName, reservation.ReservationCode)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,183 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/bridgev2/commands"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+// commandNetworkAPI returns the Hostex login that a command should use, replying with an error if there isn't one.
+func commandNetworkAPI(ce *commands.Event) *HostexNetworkAPI {
+	hn, ok := hostexClientOf(ce.User.GetDefaultLogin())
+	if !ok {
+		ce.Reply("❌ No active logins found. Please login first.")
+		return nil
+	}
+	return hn
+}
+
+// handleReservationCommand handles the reservation command. Without a reservation code,
+// it shows the reservation of the conversation the command was sent in.
+func (hc *HostexConnector) handleReservationCommand(ce *commands.Event) {
+	hn := commandNetworkAPI(ce)
+	if hn == nil {
+		return
+	}
+
+	var reservationCode string
+	if len(ce.Args) > 0 {
+		reservationCode = ce.Args[0]
+	} else if ce.Portal != nil {
+		details, err := hn.client.GetConversationDetails(ce.Ctx, string(ce.Portal.ID))
+		if err != nil {
+			ce.Reply("❌ Failed to get conversation details: %v", err)
+			return
+		}
+		for _, activity := range details.Activities {
+			if activity.ReservationCode != nil && *activity.ReservationCode != "" {
+				reservationCode = *activity.ReservationCode
+				break
+			}
+		}
+		if reservationCode == "" {
+			ce.Reply("This conversation doesn't have a reservation.")
+			return
+		}
+	} else {
+		ce.Reply("**Usage:** `$cmdprefix reservation <reservation code>`")
+		return
+	}
+
+	reservation, err := hn.client.GetReservation(ce.Ctx, reservationCode)
+	if errors.Is(err, hostexapi.ErrNotFound) {
+		ce.Reply("❌ Reservation `%s` not found.", reservationCode)
+		return
+	} else if err != nil {
+		ce.Reply("❌ Failed to get reservation: %v", err)
+		return
+	}
+	ce.Reply(formatReservation(reservation))
+}
+
+// formatReservation renders the booking details of a reservation as markdown.
+func formatReservation(reservation *hostexapi.Reservation) string {
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(&buf, "🏠 **Reservation %s** (%s)\n\n", reservation.ReservationCode, reservation.Status)
+	_, _ = fmt.Fprintf(&buf, "* **Guest:** %s", reservation.GuestName)
+	if guests := formatGuestCounts(reservation); guests != "" {
+		_, _ = fmt.Fprintf(&buf, " (%s)", guests)
+	}
+	buf.WriteString("\n")
+	if reservation.GuestPhone != "" || reservation.GuestEmail != "" {
+		_, _ = fmt.Fprintf(&buf, "* **Contact:** %s\n", strings.Trim(reservation.GuestPhone+", "+reservation.GuestEmail, ", "))
+	}
+	_, _ = fmt.Fprintf(&buf, "* **Stay:** %s → %s", reservation.CheckInDate, reservation.CheckOutDate)
+	if nights := reservation.Nights(); nights > 0 {
+		_, _ = fmt.Fprintf(&buf, " (%d nights)", nights)
+	}
+	buf.WriteString("\n")
+	if reservation.ChannelType != "" {
+		_, _ = fmt.Fprintf(&buf, "* **Booked via:** %s\n", reservation.ChannelType)
+	}
+	if reservation.Rates.TotalRate.Currency != "" {
+		_, _ = fmt.Fprintf(&buf, "* **Total:** %s\n", reservation.Rates.TotalRate)
+	}
+	if !reservation.BookedAt.IsZero() {
+		_, _ = fmt.Fprintf(&buf, "* **Booked at:** %s\n", reservation.BookedAt.Format("2006-01-02 15:04"))
+	}
+	if !reservation.CancelledAt.IsZero() {
+		_, _ = fmt.Fprintf(&buf, "* **Cancelled at:** %s\n", reservation.CancelledAt.Format("2006-01-02 15:04"))
+	}
+	if reservation.Remarks != "" {
+		_, _ = fmt.Fprintf(&buf, "* **Notes:** %s\n", reservation.Remarks)
+	}
+	return buf.String()
+}
+
+func formatGuestCounts(reservation *hostexapi.Reservation) string {
+	var counts []string
+	for _, count := range []struct {
+		n    int
+		noun string
+	}{
+		{reservation.NumberOfAdults, "adult"},
+		{reservation.NumberOfChildren, "child"},
+		{reservation.NumberOfInfants, "infant"},
+		{reservation.NumberOfPets, "pet"},
+	} {
+		if count.n == 1 {
+			counts = append(counts, "1 "+count.noun)
+		} else if count.n > 1 && count.noun == "child" {
+			counts = append(counts, fmt.Sprintf("%d children", count.n))
+		} else if count.n > 1 {
+			counts = append(counts, fmt.Sprintf("%d %ss", count.n, count.noun))
+		}
+	}
+	if len(counts) == 0 && reservation.NumberOfGuests > 0 {
+		return fmt.Sprintf("%d guests", reservation.NumberOfGuests)
+	}
+	return strings.Join(counts, ", ")
+}
+
+// findProperty finds a property by its ID or by a case-insensitive part of its title.
+func (hn *HostexNetworkAPI) findProperty(ctx context.Context, query string) (*hostexapi.Property, error) {
+	properties, err := hn.client.GetProperties(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get properties: %w", err)
+	}
+	propertyID, idErr := strconv.Atoi(query)
+	lowerQuery := strings.ToLower(query)
+	var matches []hostexapi.Property
+	for _, property := range properties {
+		if idErr == nil && property.ID == propertyID {
+			return &property, nil
+		} else if strings.EqualFold(property.Title, query) {
+			return &property, nil
+		} else if strings.Contains(strings.ToLower(property.Title), lowerQuery) {
+			matches = append(matches, property)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no property matches %q", query)
+	case 1:
+		return &matches[0], nil
+	default:
+		titles := make([]string, len(matches))
+		for i, match := range matches {
+			titles[i] = fmt.Sprintf("%s (%d)", match.Title, match.ID)
+		}
+		return nil, fmt.Errorf("%q matches multiple properties: %s", query, strings.Join(titles, ", "))
+	}
+}
+
+// parseDateRange parses one or two YYYY-MM-DD dates from the end of the arguments and returns
+// the remaining arguments. If only one date is given, the range is that single day.
+func parseDateRange(args []string) (rest []string, from, to time.Time, err error) {
+	if len(args) == 0 {
+		return nil, from, to, fmt.Errorf("missing date")
+	}
+	to, err = time.Parse(time.DateOnly, args[len(args)-1])
+	if err != nil {
+		return nil, from, to, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", args[len(args)-1])
+	}
+	rest = args[:len(args)-1]
+	from = to
+	if len(rest) > 0 {
+		if parsed, err := time.Parse(time.DateOnly, rest[len(rest)-1]); err == nil {
+			from = parsed
+			rest = rest[:len(rest)-1]
+		}
+	}
+	if to.Before(from) {
+		return nil, from, to, fmt.Errorf("end date %s is before start date %s", to.Format(time.DateOnly), from.Format(time.DateOnly))
+	}
+	return rest, from, to, nil
+}
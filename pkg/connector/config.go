@@ -0,0 +1,324 @@
+package connector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/util/configupgrade"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+const exampleConfig = `# Hostex API URL. Can be changed to point the bridge at a staging endpoint or a local mock.
+hostex_api_url: https://api.hostex.io/v3
+# Timeout in seconds for a single Hostex API request. Failed requests are retried a few times.
+hostex_api_timeout: 10
+# User agent for Hostex API requests. Leave empty for the default.
+hostex_api_user_agent: ""
+# Should guest messages and full Hostex API requests and responses be logged?
+# They contain guest messages, phone numbers and email addresses, so only enable this for debugging.
+diagnostics: false
+# Admin user to receive startup notifications
+admin_user: "@keithah:beeper.com"
+
+# Which conversations the poll loop checks for new messages.
+sync:
+    # One of:
+    #   all - every conversation on the account
+    #   recent - the most recently active conversations, limited by recent_count
+    #   active - conversations with messages within the last active_days days
+    #   reservations - conversations for current or upcoming stays (pages through every conversation)
+    scope: active
+    # Number of conversations to check when scope is recent.
+    recent_count: 50
+    # Number of days of inactivity after which a conversation is no longer checked when scope is active.
+    active_days: 30
+    # Maximum number of conversations with new messages fetched from Hostex at the same time.
+    # Messages of a single conversation are always bridged in order.
+    concurrency: 4
+
+# How often the bridge polls Hostex for new messages. All intervals are in seconds.
+poll:
+    # Interval between polls when nothing else below applies.
+    interval: 30
+    # Interval once webhook deliveries are arriving, as polling is only a safety net then.
+    webhook_interval: 300
+    # Interval while a conversation had a message within active_window minutes, or while a guest
+    # is checked in. Not used once webhook deliveries are arriving.
+    active_interval: 10
+    active_window: 30
+    # Slower interval during quiet hours in the property timezone, formatted as HH:MM-HH:MM.
+    # Recent messages still switch to active_interval. Leave empty to disable.
+    quiet_hours: "23:00-07:00"
+    quiet_interval: 300
+    # Timezone for properties that don't have one set in Hostex.
+    timezone: UTC
+    # Random variation of each interval in percent, so that logins don't all poll at the same time.
+    jitter: 10
+    # When rate limited, the interval is doubled after each limited poll up to this maximum.
+    max_backoff: 600
+
+# Hostex has no native replies, so replies from Matrix are sent with a quote of the original message.
+replies:
+    # How replies are sent to Hostex:
+    #   quote - prefix the reply with the replied-to message as "> text"
+    #   none - send only the reply text
+    fallback: quote
+    # Maximum number of characters of the replied-to message to include in the quote.
+    max_quote_length: 200
+
+# Incoming Hostex webhooks at /_matrix/mau/hostex/webhook.
+webhook:
+    # Shared secret for authenticating deliveries. Deliveries must either be signed with
+    # an HMAC-SHA256 of the body in the X-Hostex-Signature header, or carry the secret in
+    # the X-Hostex-Webhook-Token header or the token query parameter.
+    # If empty, webhooks are accepted from anyone.
+    secret: ""
+    # Maximum difference in seconds between the delivery timestamp and the current time.
+    # Deliveries without a timestamp are only checked for replays. Set to 0 to disable the timestamp check.
+    timestamp_tolerance: 300
+    # Should the bridge register a webhook with Hostex for each login? Once the first delivery
+    # arrives, polling is only used as a safety net every few minutes. Requires a secret.
+    register: true
+    # Public base URL of the bridge that Hostex can reach. Defaults to appservice.public_address.
+    public_url: ""
+
+# Bridge configuration goes here...
+`
+
+var configUpgrader = configupgrade.SimpleUpgrader(func(helper configupgrade.Helper) {
+	helper.Copy(configupgrade.Str, "hostex_api_url")
+	helper.Copy(configupgrade.Int, "hostex_api_timeout")
+	helper.Copy(configupgrade.Str, "hostex_api_user_agent")
+	helper.Copy(configupgrade.Bool, "diagnostics")
+	helper.Copy(configupgrade.Str, "admin_user")
+	helper.Copy(configupgrade.Str, "sync", "scope")
+	helper.Copy(configupgrade.Int, "sync", "recent_count")
+	helper.Copy(configupgrade.Int, "sync", "active_days")
+	helper.Copy(configupgrade.Int, "sync", "concurrency")
+	helper.Copy(configupgrade.Int, "poll", "interval")
+	helper.Copy(configupgrade.Int, "poll", "webhook_interval")
+	helper.Copy(configupgrade.Int, "poll", "active_interval")
+	helper.Copy(configupgrade.Int, "poll", "active_window")
+	helper.Copy(configupgrade.Str, "poll", "quiet_hours")
+	helper.Copy(configupgrade.Int, "poll", "quiet_interval")
+	helper.Copy(configupgrade.Str, "poll", "timezone")
+	helper.Copy(configupgrade.Int, "poll", "jitter")
+	helper.Copy(configupgrade.Int, "poll", "max_backoff")
+	helper.Copy(configupgrade.Str, "replies", "fallback")
+	helper.Copy(configupgrade.Int, "replies", "max_quote_length")
+	helper.Copy(configupgrade.Str, "webhook", "secret")
+	helper.Copy(configupgrade.Int, "webhook", "timestamp_tolerance")
+	helper.Copy(configupgrade.Bool, "webhook", "register")
+	helper.Copy(configupgrade.Str, "webhook", "public_url")
+})
+
+type HostexConfig struct {
+	HostexAPIURL       string        `yaml:"hostex_api_url"`
+	HostexAPITimeout   int           `yaml:"hostex_api_timeout"`
+	HostexAPIUserAgent string        `yaml:"hostex_api_user_agent"`
+	Diagnostics        bool          `yaml:"diagnostics"`
+	AdminUser          string        `yaml:"admin_user"`
+	Sync               SyncConfig    `yaml:"sync"`
+	Poll               PollConfig    `yaml:"poll"`
+	Replies            ReplyConfig   `yaml:"replies"`
+	Webhook            WebhookConfig `yaml:"webhook"`
+}
+
+func (hc *HostexConfig) GetAPITimeout() time.Duration {
+	if hc.HostexAPITimeout <= 0 {
+		return hostexapi.DefaultTimeout
+	}
+	return time.Duration(hc.HostexAPITimeout) * time.Second
+}
+
+// redact hides message content from logs unless diagnostics are enabled.
+func (hc *HostexConfig) redact(content string) string {
+	if hc.Diagnostics {
+		return content
+	}
+	return fmt.Sprintf("<%d characters>", len(content))
+}
+
+type SyncScope string
+
+const (
+	SyncScopeAll          SyncScope = "all"
+	SyncScopeRecent       SyncScope = "recent"
+	SyncScopeActive       SyncScope = "active"
+	SyncScopeReservations SyncScope = "reservations"
+)
+
+type SyncConfig struct {
+	Scope       SyncScope `yaml:"scope"`
+	RecentCount int       `yaml:"recent_count"`
+	ActiveDays  int       `yaml:"active_days"`
+	Concurrency int       `yaml:"concurrency"`
+}
+
+func (sc *SyncConfig) GetScope() SyncScope {
+	switch sc.Scope {
+	case SyncScopeAll, SyncScopeRecent, SyncScopeActive, SyncScopeReservations:
+		return sc.Scope
+	default:
+		return SyncScopeActive
+	}
+}
+
+func (sc *SyncConfig) GetRecentCount() int {
+	if sc.RecentCount <= 0 {
+		return 50
+	}
+	return sc.RecentCount
+}
+
+func (sc *SyncConfig) GetActiveDays() int {
+	if sc.ActiveDays <= 0 {
+		return 30
+	}
+	return sc.ActiveDays
+}
+
+// GetConcurrency returns the number of conversations fetched in parallel, capped to avoid
+// running into Hostex rate limits.
+func (sc *SyncConfig) GetConcurrency() int {
+	if sc.Concurrency <= 0 {
+		return 4
+	}
+	return min(sc.Concurrency, 16)
+}
+
+type PollConfig struct {
+	Interval        int    `yaml:"interval"`
+	WebhookInterval int    `yaml:"webhook_interval"`
+	ActiveInterval  int    `yaml:"active_interval"`
+	ActiveWindow    int    `yaml:"active_window"`
+	QuietHours      string `yaml:"quiet_hours"`
+	QuietInterval   int    `yaml:"quiet_interval"`
+	Timezone        string `yaml:"timezone"`
+	Jitter          int    `yaml:"jitter"`
+	MaxBackoff      int    `yaml:"max_backoff"`
+}
+
+func secondsOrDefault(seconds int, def time.Duration) time.Duration {
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (pc *PollConfig) GetInterval() time.Duration {
+	return secondsOrDefault(pc.Interval, 30*time.Second)
+}
+
+func (pc *PollConfig) GetWebhookInterval() time.Duration {
+	return secondsOrDefault(pc.WebhookInterval, 5*time.Minute)
+}
+
+func (pc *PollConfig) GetActiveInterval() time.Duration {
+	return secondsOrDefault(pc.ActiveInterval, 10*time.Second)
+}
+
+func (pc *PollConfig) GetActiveWindow() time.Duration {
+	if pc.ActiveWindow <= 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(pc.ActiveWindow) * time.Minute
+}
+
+func (pc *PollConfig) GetQuietInterval() time.Duration {
+	return secondsOrDefault(pc.QuietInterval, 5*time.Minute)
+}
+
+func (pc *PollConfig) GetMaxBackoff() time.Duration {
+	return secondsOrDefault(pc.MaxBackoff, 10*time.Minute)
+}
+
+// GetJitter returns the maximum variation of intervals as a fraction.
+func (pc *PollConfig) GetJitter() float64 {
+	if pc.Jitter <= 0 {
+		return 0
+	}
+	return float64(min(pc.Jitter, 50)) / 100
+}
+
+// GetTimezone returns the location used for properties without a timezone.
+func (pc *PollConfig) GetTimezone() *time.Location {
+	if pc.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(pc.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// GetQuietHours parses the quiet hours into minutes after midnight. The range may wrap past midnight.
+func (pc *PollConfig) GetQuietHours() (start, end int, ok bool) {
+	startStr, endStr, found := strings.Cut(pc.QuietHours, "-")
+	if !found {
+		return 0, 0, false
+	}
+	startTime, err := time.Parse("15:04", strings.TrimSpace(startStr))
+	if err != nil {
+		return 0, 0, false
+	}
+	endTime, err := time.Parse("15:04", strings.TrimSpace(endStr))
+	if err != nil {
+		return 0, 0, false
+	}
+	start = startTime.Hour()*60 + startTime.Minute()
+	end = endTime.Hour()*60 + endTime.Minute()
+	return start, end, start != end
+}
+
+type ReplyFallback string
+
+const (
+	ReplyFallbackQuote ReplyFallback = "quote"
+	ReplyFallbackNone  ReplyFallback = "none"
+)
+
+type ReplyConfig struct {
+	Fallback       ReplyFallback `yaml:"fallback"`
+	MaxQuoteLength int           `yaml:"max_quote_length"`
+}
+
+func (rc *ReplyConfig) GetFallback() ReplyFallback {
+	if rc.Fallback == ReplyFallbackNone {
+		return ReplyFallbackNone
+	}
+	return ReplyFallbackQuote
+}
+
+func (rc *ReplyConfig) GetMaxQuoteLength() int {
+	if rc.MaxQuoteLength <= 0 {
+		return 200
+	}
+	return rc.MaxQuoteLength
+}
+
+type WebhookConfig struct {
+	Secret             string `yaml:"secret"`
+	TimestampTolerance int    `yaml:"timestamp_tolerance"`
+	Register           bool   `yaml:"register"`
+	PublicURL          string `yaml:"public_url"`
+}
+
+func (wc *WebhookConfig) GetTimestampTolerance() time.Duration {
+	if wc.TimestampTolerance <= 0 {
+		return 0
+	}
+	return time.Duration(wc.TimestampTolerance) * time.Second
+}
+
+// GetDedupeWindow returns how long delivered event IDs are remembered. Anything older
+// than the timestamp window is rejected anyway, so that's all that needs to be kept.
+func (wc *WebhookConfig) GetDedupeWindow() time.Duration {
+	if tolerance := wc.GetTimestampTolerance(); tolerance > 0 {
+		return 2 * tolerance
+	}
+	return 24 * time.Hour
+}
@@ -2,13 +2,16 @@ package connector
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hostex-matrix-bridge/pkg/hostexapi"
-	"io"
+	"hostex-matrix-bridge/pkg/hostexdb"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -17,50 +20,49 @@ import (
 	"maunium.net/go/mautrix/bridgev2/commands"
 	"maunium.net/go/mautrix/bridgev2/database"
 	"maunium.net/go/mautrix/bridgev2/networkid"
+	"maunium.net/go/mautrix/bridgev2/simplevent"
 	"maunium.net/go/mautrix/bridgev2/status"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
 )
 
-const exampleConfig = `# Hostex API URL
-hostex_api_url: https://api.hostex.io/v3
-# Admin user to receive startup notifications
-admin_user: "@keithah:beeper.com"
-
-# Bridge configuration goes here...
-`
-
-var configUpgrader = configupgrade.SimpleUpgrader(func(helper configupgrade.Helper) {
-	helper.Copy(configupgrade.Str, "hostex_api_url")
-	helper.Copy(configupgrade.Str, "admin_user")
-})
-
-type HostexConfig struct {
-	HostexAPIURL string `yaml:"hostex_api_url"`
-	AdminUser    string `yaml:"admin_user"`
-}
-
 type HostexConnector struct {
-	br *bridgev2.Bridge
+	br     *bridgev2.Bridge
+	DB     *hostexdb.Database
+	Config HostexConfig
+
+	webhookDedupe  *webhookDedupe
+	webhookMetrics webhookMetrics
 }
 
 var _ bridgev2.NetworkConnector = (*HostexConnector)(nil)
 
 func (hc *HostexConnector) Init(bridge *bridgev2.Bridge) {
 	hc.br = bridge
+	hc.DB = hostexdb.New(bridge.ID, bridge.DB.Database, bridge.Log.With().Str("db_section", "hostex").Logger())
+	hc.webhookDedupe = newWebhookDedupe(hc.Config.Webhook.GetDedupeWindow())
 }
 
 func (hc *HostexConnector) Start(ctx context.Context) error {
 	hc.br.Log.Info().Msg("Starting Hostex connector")
 
+	if err := hc.DB.Upgrade(ctx); err != nil {
+		return bridgev2.DBUpgradeError{Err: err, Section: "hostex"}
+	}
+
 	// Register HTTP endpoints for webhooks
 	if server, ok := hc.br.Matrix.(bridgev2.MatrixConnectorWithServer); ok {
 		router := server.GetRouter()
 		if router != nil {
 			// Register webhook endpoint (http.ServeMux uses full paths)
 			router.HandleFunc("POST /_matrix/mau/hostex/webhook", hc.handleWebhook)
+			// Webhooks registered automatically carry the login they belong to
+			router.HandleFunc("POST /_matrix/mau/hostex/webhook/{webhookID}", hc.handleWebhook)
 			router.HandleFunc("GET /_matrix/mau/hostex/health", hc.handleHealth)
 			hc.br.Log.Info().Msg("Registered HTTP endpoints for webhooks")
+			if hc.Config.Webhook.Secret == "" {
+				hc.br.Log.Warn().Msg("No webhook secret configured - webhook deliveries are not authenticated")
+			}
 		} else {
 			hc.br.Log.Warn().Msg("Router is nil - webhooks disabled")
 		}
@@ -98,6 +100,46 @@ func (hc *HostexConnector) Start(ctx context.Context) error {
 			},
 			RequiresLogin: true,
 		},
+		&commands.FullHandler{
+			Func: hc.handleReservationCommand,
+			Name: "reservation",
+			Help: commands.HelpMeta{
+				Section:     commands.HelpSectionGeneral,
+				Description: "Show the booking details of a reservation",
+				Args:        "[_reservation code_]",
+			},
+			RequiresLogin: true,
+		},
+		&commands.FullHandler{
+			Func: hc.handleCalendarCommand,
+			Name: "calendar",
+			Help: commands.HelpMeta{
+				Section:     commands.HelpSectionGeneral,
+				Description: "Show the occupancy of a property, or block and unblock dates",
+				Args:        "[block|unblock] <_property_> [_month_ | _from_ [_to_]]",
+			},
+			RequiresLogin: true,
+		},
+		&commands.FullHandler{
+			Func: hc.handlePriceCommand,
+			Name: "price",
+			Help: commands.HelpMeta{
+				Section:     commands.HelpSectionGeneral,
+				Description: "Show or change the nightly rates of a property",
+				Args:        "<_property_> <_from_> <_to_> [_amount_]",
+			},
+			RequiresLogin: true,
+		},
+		&commands.FullHandler{
+			Func: hc.handleReviewReplyCommand,
+			Name: "review-reply",
+			Help: commands.HelpMeta{
+				Section:     commands.HelpSectionGeneral,
+				Description: "Publicly respond to the guest review of a reservation",
+				Args:        "<_reservation code_> <_response_>",
+			},
+			RequiresLogin: true,
+		},
 	)
 	hc.br.Log.Info().Msg("Custom command handlers ENABLED for room cleanup")
 
@@ -129,11 +171,11 @@ func (hc *HostexConnector) GetCapabilities() *bridgev2.NetworkGeneralCapabilitie
 
 func (hc *HostexConnector) GetBridgeInfoVersion() (info, capabilities int) {
 	// Note: hc.br is nil during early initialization, can't log here
-	return 1, 1
+	return 1, 2
 }
 
 func (hc *HostexConnector) GetConfig() (example string, data any, upgrader configupgrade.Upgrader) {
-	return exampleConfig, &HostexConfig{}, configUpgrader
+	return exampleConfig, &hc.Config, configUpgrader
 }
 
 func (hc *HostexConnector) GetDBMetaTypes() database.MetaTypes {
@@ -142,6 +184,7 @@ func (hc *HostexConnector) GetDBMetaTypes() database.MetaTypes {
 		Portal:    func() any { return &HostexPortalMetadata{} },
 		Ghost:     func() any { return &HostexGhostMetadata{} },
 		UserLogin: func() any { return &HostexUserLoginMetadata{} },
+		Message:   func() any { return &HostexMessageMetadata{} },
 	}
 }
 
@@ -157,34 +200,57 @@ func (hc *HostexConnector) CreateLogin(ctx context.Context, user *bridgev2.User,
 	switch flowID {
 	case "token":
 		return &HostexLogin{
-			br:   hc.br,
-			user: user,
+			br:        hc.br,
+			connector: hc,
+			user:      user,
 		}, nil
 	default:
 		return nil, fmt.Errorf("unknown login flow ID: %s", flowID)
 	}
 }
 
+// newClient creates a Hostex API client configured from the network config.
+func (hc *HostexConnector) newClient(accessToken string, log zerolog.Logger) *hostexapi.Client {
+	return hostexapi.NewClient(accessToken,
+		hostexapi.WithBaseURL(hc.Config.HostexAPIURL),
+		hostexapi.WithTimeout(hc.Config.GetAPITimeout()),
+		hostexapi.WithUserAgent(hc.Config.HostexAPIUserAgent),
+		hostexapi.WithLogger(log.With().Str("component", "hostex_api").Logger()),
+		hostexapi.WithDiagnostics(hc.Config.Diagnostics),
+	)
+}
+
 func (hc *HostexConnector) LoadUserLogin(ctx context.Context, login *bridgev2.UserLogin) error {
 	meta := login.Metadata.(*HostexUserLoginMetadata)
-	client := hostexapi.NewClient(meta.AccessToken)
+	client := hc.newClient(meta.AccessToken, login.Log)
 
 	nl := &HostexNetworkAPI{
-		br:                      hc.br,
-		login:                   login,
-		client:                  client,
-		guestNames:              make(map[string]string),
-		lastMessageTime:         make(map[string]time.Time),
-		conversationLastMsgTime: make(map[string]time.Time),
-		sentMessages:            make(map[string]time.Time),
+		br:        hc.br,
+		connector: hc,
+		login:     login,
+		client:    client,
+		state:     newStateStore(),
 	}
 
+	// Restore sync cursors so polling resumes where it left off before the restart
+	cursors, err := hc.DB.SyncCursor.GetAllForLogin(ctx, login.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load sync cursors: %w", err)
+	}
+	for _, cursor := range cursors {
+		nl.state.restoreCursors(cursor.ConversationID, cursor.LastMessageAt, cursor.LastProcessedAt)
+	}
+	hc.br.Log.Debug().Str("login_id", string(login.ID)).Int("cursor_count", len(cursors)).Msg("Restored conversation sync cursors")
+
 	login.Client = nl
 	return nil
 }
 
 type HostexUserLoginMetadata struct {
 	AccessToken string `json:"access_token"`
+	// WebhookID identifies the login in its webhook URL without revealing the login ID,
+	// which contains the start of the access token
+	WebhookID string `json:"webhook_id,omitempty"`
 }
 
 type HostexPortalMetadata struct {
@@ -195,9 +261,15 @@ type HostexGhostMetadata struct {
 	Name string `json:"name"`
 }
 
+type HostexMessageMetadata struct {
+	// Text is the message text without any quoted reply, used to quote the message in replies
+	Text string `json:"text,omitempty"`
+}
+
 type HostexLogin struct {
-	br   *bridgev2.Bridge
-	user *bridgev2.User
+	br        *bridgev2.Bridge
+	connector *HostexConnector
+	user      *bridgev2.User
 }
 
 var _ bridgev2.LoginProcess = (*HostexLogin)(nil)
@@ -234,12 +306,15 @@ func (hl *HostexLogin) SubmitUserInput(ctx context.Context, input map[string]str
 
 	// Test the API token by making a request
 	hl.br.Log.Info().Msg("SubmitUserInput: Testing API token with Hostex API")
-	client := hostexapi.NewClient(accessToken)
+	client := hl.connector.newClient(accessToken, hl.user.Log)
 
 	// Test the connection by getting properties
 	properties, err := client.GetProperties(ctx)
 	if err != nil {
 		hl.br.Log.Error().Err(err).Msg("SubmitUserInput: Failed to authenticate with Hostex API")
+		if errors.Is(err, hostexapi.ErrUnauthorized) {
+			return nil, fmt.Errorf("invalid Hostex access token: %w", err)
+		}
 		return nil, fmt.Errorf("failed to authenticate with Hostex API: %w", err)
 	}
 
@@ -275,42 +350,63 @@ func (hl *HostexLogin) SubmitUserInput(ctx context.Context, input map[string]str
 }
 
 type HostexNetworkAPI struct {
-	br                      *bridgev2.Bridge
-	login                   *bridgev2.UserLogin
-	client                  *hostexapi.Client
-	guestNames              map[string]string    // conversation ID -> guest name mapping
-	lastMessageTime         map[string]time.Time // conversation ID -> timestamp of last processed message
-	lastMessageTimeMu       sync.RWMutex         // protects lastMessageTime map
-	conversationLastMsgTime map[string]time.Time // conversation ID -> last_message_at from conversations endpoint
-	conversationLastMsgMu   sync.RWMutex         // protects conversationLastMsgTime map
-	sentMessages            map[string]time.Time // message content -> timestamp of sent message (to prevent echo)
-	sentMessagesMu          sync.RWMutex         // protects sentMessages map
+	br                   *bridgev2.Bridge
+	connector            *HostexConnector
+	login                *bridgev2.UserLogin
+	client               *hostexapi.Client
+	state                *stateStore // guest names, sync cursors, pending echoes and bridged reviews
+	pollFailures         int         // number of poll cycles in a row that failed
+	lastBridgeState      status.BridgeStateEvent
+	lastBridgeStateError status.BridgeStateErrorCode
+	bridgeStateMu        sync.Mutex         // protects pollFailures and the last bridge state
+	stopPolling          context.CancelFunc // cancels the poll worker, nil if it isn't running
+	pollDone             chan struct{}      // closed when the poll worker has exited
+	pollMu               sync.Mutex         // protects stopPolling and pollDone
+	schedule             pollSchedule       // activity and backoff for deciding when to poll next
+	webhookDelivered     atomic.Bool        // whether webhooks for this login are arriving, reset when a poll finds missed ones
 }
 
 var _ bridgev2.NetworkAPI = (*HostexNetworkAPI)(nil)
 
 func (hn *HostexNetworkAPI) Connect(ctx context.Context) {
-	hn.br.Log.Info().Str("user_login", string(hn.login.ID)).Msg("Connecting to Hostex")
-
-	// Send bridge state to indicate we're connected
-	if hn.login.BridgeState != nil {
-		hn.br.Log.Info().Str("user_login", string(hn.login.ID)).Msg("Sending CONNECTED bridge state")
-		hn.login.BridgeState.Send(status.BridgeState{
-			StateEvent: status.StateConnected,
-			Info: map[string]any{
-				"conn_timestamp": time.Now().Unix(),
-			},
-		})
-	} else {
-		hn.br.Log.Warn().Str("user_login", string(hn.login.ID)).Msg("BridgeState is nil, cannot send status")
+	hn.pollMu.Lock()
+	defer hn.pollMu.Unlock()
+	if hn.stopPolling != nil {
+		hn.br.Log.Debug().Str("user_login", string(hn.login.ID)).Msg("Already connected to Hostex, not starting another poller")
+		return
 	}
+	hn.br.Log.Info().Str("user_login", string(hn.login.ID)).Msg("Connecting to Hostex")
 
-	// Start polling for conversations and messages
-	go hn.pollConversations(ctx)
+	// Polling reports bad credentials or outages if the first cycles fail
+	hn.bridgeStateMu.Lock()
+	hn.pollFailures = 0
+	hn.lastBridgeState = ""
+	hn.bridgeStateMu.Unlock()
+	hn.sendConnectedState()
+
+	// The poller outlives the context Connect was called with and only stops on Disconnect
+	pollCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	hn.stopPolling = cancel
+	hn.pollDone = done
+	go func() {
+		defer close(done)
+		hn.pollConversations(pollCtx)
+	}()
 }
 
 func (hn *HostexNetworkAPI) Disconnect() {
+	hn.pollMu.Lock()
+	defer hn.pollMu.Unlock()
+	if hn.stopPolling == nil {
+		return
+	}
 	hn.br.Log.Info().Str("user_login", string(hn.login.ID)).Msg("Disconnecting from Hostex")
+	hn.stopPolling()
+	<-hn.pollDone
+	hn.stopPolling = nil
+	hn.pollDone = nil
+	hn.br.Log.Debug().Str("user_login", string(hn.login.ID)).Msg("Hostex poller stopped")
 }
 
 func (hn *HostexNetworkAPI) IsLoggedIn() bool {
@@ -318,7 +414,9 @@ func (hn *HostexNetworkAPI) IsLoggedIn() bool {
 }
 
 func (hn *HostexNetworkAPI) LogoutRemote(ctx context.Context) {
-	// Hostex doesn't have a logout endpoint, just disconnect
+	// Hostex doesn't have a logout endpoint, just stop polling and webhook deliveries for this login
+	hn.Disconnect()
+	hn.removeWebhook(ctx)
 }
 
 func (hn *HostexNetworkAPI) IsThisUser(ctx context.Context, userID networkid.UserID) bool {
@@ -344,7 +442,7 @@ func (hn *HostexNetworkAPI) GetUserInfo(ctx context.Context, ghost *bridgev2.Gho
 	} else if strings.HasPrefix(userIDStr, "guest_") {
 		// Guest user - extract conversation ID and get guest name
 		conversationID := strings.TrimPrefix(userIDStr, "guest_")
-		if guestName, exists := hn.guestNames[conversationID]; exists && guestName != "" {
+		if guestName := hn.state.guestName(conversationID); guestName != "" {
 			name = guestName
 		} else {
 			// Try to get name from stored metadata
@@ -375,15 +473,32 @@ func (hn *HostexNetworkAPI) GetCapabilities(ctx context.Context, portal *bridgev
 		Reaction:            event.CapLevelUnsupported,
 		ReadReceipts:        false,
 		TypingNotifications: false,
+		File: event.FileFeatureMap{
+			event.MsgImage: hostexImageFeatures,
+			event.MsgFile:  hostexImageFeatures,
+		},
 	}
 }
 
+// hostexImageFeatures describes the images that can be converted to JPEG and sent to Hostex.
+var hostexImageFeatures = &event.FileFeatures{
+	MimeTypes: map[string]event.CapabilitySupportLevel{
+		"image/jpeg": event.CapLevelFullySupported,
+		"image/png":  event.CapLevelPartialSupport,
+		"image/gif":  event.CapLevelPartialSupport,
+		"image/webp": event.CapLevelPartialSupport,
+	},
+	Caption:          event.CapLevelFullySupported,
+	MaxCaptionLength: 4000,
+	MaxSize:          matrixMaxImageSize,
+}
+
 func (hn *HostexNetworkAPI) HandleMatrixMessage(ctx context.Context, msg *bridgev2.MatrixMessage) (*bridgev2.MatrixMessageResponse, error) {
 	// This handles messages in portal rooms, not commands
 	hn.br.Log.Info().
 		Str("room_id", string(msg.Event.RoomID)).
 		Str("sender", string(msg.Event.Sender)).
-		Str("content", msg.Content.Body).
+		Str("content", hn.connector.Config.redact(msg.Content.Body)).
 		Msg("Received Matrix message to send to Hostex")
 
 	// Get the portal to find the conversation ID
@@ -397,11 +512,37 @@ func (hn *HostexNetworkAPI) HandleMatrixMessage(ctx context.Context, msg *bridge
 
 	hn.br.Log.Info().
 		Str("conversation_id", conversationID).
-		Str("content", msg.Content.Body).
+		Str("content", hn.connector.Config.redact(msg.Content.Body)).
+		Str("msg_type", string(msg.Content.MsgType)).
 		Msg("Sending message to Hostex conversation")
 
+	// Replies to a bridged guest review are posted as the public response to the review
+	if msg.ReplyTo != nil {
+		if reservationCode, ok := parseReviewMessageID(msg.ReplyTo.ID); ok {
+			return hn.handleMatrixReviewReply(ctx, msg, reservationCode)
+		}
+	}
+
+	// Hostex only supports text and JPEG images, so other media can't be sent
+	var text, jpegData string
+	switch msg.Content.MsgType {
+	case event.MsgText, event.MsgNotice, event.MsgEmote:
+		text = msg.Content.Body
+	case event.MsgImage, event.MsgFile:
+		var err error
+		jpegData, err = hn.prepareMatrixImage(ctx, msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		text = msg.Content.GetCaption()
+	default:
+		return nil, bridgev2.ErrUnsupportedMessageType
+	}
+	replyText := text
+	text = hn.formatReplyFallback(ctx, msg, text)
+
 	// Send message to Hostex
-	sentMessage, err := hn.client.SendMessage(ctx, conversationID, msg.Content.Body)
+	sentMessage, err := hn.client.SendMessageWithImage(ctx, conversationID, text, jpegData)
 	if err != nil {
 		hn.br.Log.Error().Err(err).
 			Str("conversation_id", conversationID).
@@ -409,15 +550,21 @@ func (hn *HostexNetworkAPI) HandleMatrixMessage(ctx context.Context, msg *bridge
 		return nil, fmt.Errorf("failed to send message to Hostex: %w", err)
 	}
 
-	// Track sent message to prevent echo
-	hn.sentMessagesMu.Lock()
-	hn.sentMessages[msg.Content.Body] = time.Now()
-	hn.sentMessagesMu.Unlock()
+	// Two identical messages sent in quick succession can resolve to the same Hostex message,
+	// in which case this one keeps a pending ID until its echo is reconciled
+	if !hostexapi.IsPendingMessageID(sentMessage.ID) {
+		existing, err := hn.br.DB.Message.GetFirstPartByID(ctx, hn.login.ID, networkid.MessageID(sentMessage.ID))
+		if err != nil {
+			hn.br.Log.Warn().Err(err).Str("message_id", sentMessage.ID).Msg("Failed to check for existing message with sent message ID")
+		} else if existing != nil {
+			sentMessage.ID = hostexapi.PendingMessageID(time.Now())
+		}
+	}
 
 	hn.br.Log.Info().
 		Str("conversation_id", conversationID).
 		Str("message_id", sentMessage.ID).
-		Str("content", sentMessage.Content).
+		Str("content", hn.connector.Config.redact(sentMessage.Content)).
 		Msg("Successfully sent message to Hostex")
 
 	// Return response with the sent message details
@@ -428,10 +575,56 @@ func (hn *HostexNetworkAPI) HandleMatrixMessage(ctx context.Context, msg *bridge
 			Room:      portal.PortalKey,
 			SenderID:  networkid.UserID("host_" + string(hn.login.ID)),
 			Timestamp: sentMessage.CreatedAt,
+			Metadata:  &HostexMessageMetadata{Text: replyText},
+		},
+		// Track the sent message once it's saved, so its echo in the Hostex history can be reconciled
+		PostSave: func(ctx context.Context, dbMessage *database.Message) {
+			hn.state.addPendingMessage(conversationID, &pendingMessage{
+				MXID:        dbMessage.MXID,
+				MessageID:   dbMessage.ID,
+				ContentHash: hashMessageContent(text),
+				HasImage:    jpegData != "",
+				SentAt:      sentMessage.CreatedAt,
+			})
 		},
 	}, nil
 }
 
+// prepareMatrixImage downloads an image from Matrix and converts it to the base64 JPEG Hostex expects.
+func (hn *HostexNetworkAPI) prepareMatrixImage(ctx context.Context, content *event.MessageEventContent) (string, error) {
+	var mimeType string
+	if content.Info != nil {
+		mimeType = content.Info.MimeType
+		if content.Info.Size > matrixMaxImageSize {
+			return "", fmt.Errorf("%w: %d bytes", bridgev2.ErrMediaTooLarge, content.Info.Size)
+		}
+	}
+	if mimeType != "" && !hostexImageMimeTypes[mimeType] {
+		return "", fmt.Errorf("%w: %s", bridgev2.ErrUnsupportedMediaType, mimeType)
+	}
+
+	// The declared size can be missing or wrong, so the download is limited as well
+	data, err := hn.downloadMatrixMedia(ctx, content.URL, content.File, matrixMaxImageSize)
+	if errors.Is(err, errFileTooLarge) {
+		return "", fmt.Errorf("%w: %w", bridgev2.ErrMediaTooLarge, err)
+	} else if err != nil {
+		return "", fmt.Errorf("%w: %w", bridgev2.ErrMediaDownloadFailed, err)
+	}
+	if mimeType == "" {
+		if mimeType = http.DetectContentType(data); !hostexImageMimeTypes[mimeType] {
+			return "", fmt.Errorf("%w: %s", bridgev2.ErrUnsupportedMediaType, mimeType)
+		}
+	}
+
+	jpegData, err := convertImageForHostex(data)
+	if errors.Is(err, errImageTooLarge) {
+		return "", fmt.Errorf("%w: %w", bridgev2.ErrMediaTooLarge, err)
+	} else if err != nil {
+		return "", fmt.Errorf("%w: %w", bridgev2.ErrMediaConvertFailed, err)
+	}
+	return base64.StdEncoding.EncodeToString(jpegData), nil
+}
+
 func (hn *HostexNetworkAPI) ResolveIdentifier(ctx context.Context, identifier string, createChat bool) (*bridgev2.ResolveIdentifierResponse, error) {
 	// Try to parse as conversation ID
 	if strings.HasPrefix(identifier, "conv_") {
@@ -461,38 +654,96 @@ func (hn *HostexNetworkAPI) ResolveIdentifier(ctx context.Context, identifier st
 }
 
 func (hn *HostexNetworkAPI) pollConversations(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+	webhookRegistered := hn.ensureWebhook(ctx)
+	// A registered webhook only replaces fast polling once a delivery has actually arrived,
+	// as Hostex accepts URLs it can't reach
+	webhooks := func() bool {
+		return webhookRegistered && hn.webhookDelivered.Load()
+	}
+	reviewTicker := time.NewTicker(reviewPollInterval)
+	defer reviewTicker.Stop()
+
+	// Sync right away instead of waiting for the first poll
+	err := hn.syncConversations(ctx)
+	hn.reportPollResult(ctx, err)
+	hn.syncReviews(ctx)
+	timer := time.NewTimer(hn.nextPollDelay(ctx, err, webhooks()))
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			hn.syncConversations(ctx)
+		case <-timer.C:
+			err = hn.syncConversations(ctx)
+			hn.reportPollResult(ctx, err)
+			timer.Reset(hn.nextPollDelay(ctx, err, webhooks()))
+		case <-reviewTicker.C:
+			hn.syncReviews(ctx)
 		}
 	}
 }
 
-func (hn *HostexNetworkAPI) syncConversations(ctx context.Context) {
-	conversations, err := hn.client.GetConversations(ctx)
-	if err != nil {
-		hn.br.Log.Error().Err(err).Msg("Failed to fetch conversations")
-		return
+// conversationsInScope fetches the conversations covered by the configured sync scope.
+// The conversations endpoint returns the most recently active conversations first,
+// so the recent and active scopes stop paging as soon as they reach their limit.
+func (hn *HostexNetworkAPI) conversationsInScope(ctx context.Context) ([]hostexapi.Conversation, error) {
+	syncConfig := &hn.connector.Config.Sync
+	scope := syncConfig.GetScope()
+	activeSince := time.Now().AddDate(0, 0, -syncConfig.GetActiveDays())
+	today := time.Now().Format(time.DateOnly)
+
+	var conversations []hostexapi.Conversation
+	for conv, err := range hn.client.IterateConversations(ctx, hostexapi.ListOptions{Limit: hostexapi.MaxPageSize}) {
+		if err != nil {
+			return nil, err
+		}
+		switch scope {
+		case SyncScopeRecent:
+			if len(conversations) >= syncConfig.GetRecentCount() {
+				return conversations, nil
+			}
+		case SyncScopeActive:
+			if conv.LastMessageAt.Before(activeSince) {
+				return conversations, nil
+			}
+		case SyncScopeReservations:
+			// Dates are YYYY-MM-DD, so plain string comparison orders them correctly
+			if conv.CheckOutDate == "" || conv.CheckOutDate < today {
+				continue
+			}
+		}
+		conversations = append(conversations, conv)
 	}
+	return conversations, nil
+}
 
-	// Only sync the last 10 conversations as requested by the user
-	if len(conversations) > 10 {
-		conversations = conversations[:10]
+// syncConversations checks the conversations in scope for new messages. The returned error
+// is only set if the poll cycle as a whole failed, failures of single conversations are logged.
+func (hn *HostexNetworkAPI) syncConversations(ctx context.Context) error {
+	conversations, err := hn.conversationsInScope(ctx)
+	if errors.Is(err, hostexapi.ErrRateLimited) {
+		hn.br.Log.Warn().Err(err).Msg("Rate limited while fetching conversations, skipping poll cycle")
+		return err
+	} else if err != nil {
+		hn.br.Log.Error().Err(err).Msg("Failed to fetch conversations")
+		return fmt.Errorf("failed to fetch conversations: %w", err)
 	}
 
-	hn.br.Log.Info().Int("conversation_count", len(conversations)).Msg("Checking conversations for new messages")
+	hn.schedule.observeConversations(conversations, time.Now())
+
+	hn.br.Log.Info().
+		Int("conversation_count", len(conversations)).
+		Str("sync_scope", string(hn.connector.Config.Sync.GetScope())).
+		Msg("Checking conversations for new messages")
 
+	var changed []hostexapi.Conversation
+	missedWebhooks := 0
 	for _, conv := range conversations {
 		// Check if we need to process this conversation based on last_message_at
-		hn.conversationLastMsgMu.RLock()
-		cachedLastMsgTime, hasCached := hn.conversationLastMsgTime[conv.ID]
-		hn.conversationLastMsgMu.RUnlock()
+		hn.loadSyncCursor(ctx, conv.ID)
+		cachedLastMsgTime, _ := hn.state.cursors(conv.ID)
+		hasCached := !cachedLastMsgTime.IsZero()
 
 		// Skip if no new messages since last check
 		if hasCached && !conv.LastMessageAt.After(cachedLastMsgTime) {
@@ -504,24 +755,167 @@ func (hn *HostexNetworkAPI) syncConversations(ctx context.Context) {
 				Msg("Skipping conversation - no new messages")
 			continue
 		}
+		changed = append(changed, conv)
+		if hasCached && time.Since(conv.LastMessageAt) > webhookDeliveryGracePeriod {
+			missedWebhooks++
+		}
+	}
+	hn.checkMissedWebhooks(missedWebhooks)
+	return hn.syncChangedConversations(ctx, changed)
+}
 
-		hn.br.Log.Info().
-			Str("conversation_id", conv.ID).
-			Str("guest_name", conv.Guest.Name).
-			Str("last_message_at", conv.LastMessageAt.String()).
-			Msg("Processing conversation with new messages")
+// syncChangedConversations fetches and processes conversations with new messages using a bounded
+// number of workers. Each conversation is handled entirely by one worker while holding its lock,
+// so its messages are still queued in order. Once Hostex rate limits a request or rejects the
+// token, no more conversations are started and the remaining ones are left for the next poll.
+func (hn *HostexNetworkAPI) syncChangedConversations(ctx context.Context, conversations []hostexapi.Conversation) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+	concurrency := min(hn.connector.Config.Sync.GetConcurrency(), len(conversations))
+	hn.br.Log.Debug().
+		Int("conversation_count", len(conversations)).
+		Int("concurrency", concurrency).
+		Msg("Syncing conversations with new messages")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stopErr error
+	var stopOnce sync.Once
+	stop := func(err error) {
+		stopOnce.Do(func() {
+			stopErr = err
+			cancel()
+		})
+	}
 
-		// Update cached timestamp
-		hn.conversationLastMsgMu.Lock()
-		hn.conversationLastMsgTime[conv.ID] = conv.LastMessageAt
-		hn.conversationLastMsgMu.Unlock()
+	queue := make(chan hostexapi.Conversation)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for conv := range queue {
+				hn.br.Log.Info().
+					Str("conversation_id", conv.ID).
+					Str("guest_name", conv.Guest.Name).
+					Str("last_message_at", conv.LastMessageAt.String()).
+					Msg("Processing conversation with new messages")
+
+				if err := hn.syncConversation(ctx, conv, nil); errors.Is(err, hostexapi.ErrNotFound) {
+					hn.br.Log.Warn().Err(err).Str("conversation_id", conv.ID).Msg("Conversation no longer exists on Hostex")
+				} else if errors.Is(err, hostexapi.ErrRateLimited) {
+					// The remaining conversations would most likely be rate limited too, so try them on the next poll
+					hn.br.Log.Warn().Err(err).Str("conversation_id", conv.ID).Msg("Rate limited while processing conversations, stopping poll cycle")
+					stop(err)
+				} else if errors.Is(err, hostexapi.ErrUnauthorized) {
+					hn.br.Log.Error().Err(err).Str("conversation_id", conv.ID).Msg("Hostex rejected the access token, stopping poll cycle")
+					stop(err)
+				} else if err != nil && ctx.Err() == nil {
+					hn.br.Log.Error().Err(err).Str("conversation_id", conv.ID).Msg("Failed to process conversation")
+				}
+			}
+		}()
+	}
 
-		// Process this conversation
-		hn.processConversation(ctx, conv)
+Dispatch:
+	for _, conv := range conversations {
+		select {
+		case queue <- conv:
+		case <-ctx.Done():
+			break Dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return stopErr
+}
+
+// syncConversation queues new messages of a conversation and advances its sync cursors.
+// If details is nil, they're fetched from Hostex. The cached last_message_at is only
+// advanced if processing succeeded, so a failed fetch is retried on the next poll
+// instead of leaving a gap.
+func (hn *HostexNetworkAPI) syncConversation(ctx context.Context, conv hostexapi.Conversation, details *hostexapi.ConversationDetails) error {
+	unlock := hn.state.lockConversation(conv.ID)
+	defer unlock()
+
+	if details == nil {
+		hn.br.Log.Debug().Str("conversation_id", conv.ID).Msg("Fetching conversation details from Hostex API")
+		var err error
+		details, err = hn.client.GetConversationDetails(ctx, conv.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get conversation details: %w", err)
+		}
+		hn.br.Log.Debug().Str("conversation_id", conv.ID).Int("message_count", len(details.Messages)).Msg("Got conversation details from Hostex API")
+	}
+
+	hn.loadSyncCursor(ctx, conv.ID)
+	hn.processConversation(ctx, conv, details)
+	hn.state.setLastMessageAt(conv.ID, conv.LastMessageAt)
+	return nil
+}
+
+// refreshConversation fetches a single conversation by ID and bridges any new messages,
+// without waiting for it to show up in the next poll.
+func (hn *HostexNetworkAPI) refreshConversation(ctx context.Context, conversationID string) error {
+	details, err := hn.client.GetConversationDetails(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation details: %w", err)
+	}
+	return hn.syncConversation(ctx, conversationFromDetails(conversationID, details), details)
+}
+
+// conversationFromDetails builds the conversation list entry equivalent of conversation details.
+// The requested ID is used rather than the one in the response, so the portal key always
+// matches the conversation that was asked for.
+func conversationFromDetails(conversationID string, details *hostexapi.ConversationDetails) hostexapi.Conversation {
+	conv := hostexapi.Conversation{
+		ID:          conversationID,
+		ChannelType: details.ChannelType,
+		Guest:       details.Guest,
+	}
+	if len(details.Messages) > 0 {
+		conv.LastMessageAt = details.Messages[0].CreatedAt // Messages are in reverse chronological order
+	}
+	if len(details.Activities) > 0 {
+		conv.PropertyTitle = details.Activities[0].Property.Title
+		conv.CheckInDate = details.Activities[0].CheckInDate
+		conv.CheckOutDate = details.Activities[0].CheckOutDate
+	}
+	return conv
+}
+
+// loadSyncCursor restores the sync cursors of a conversation from the database if they were
+// evicted from memory, so an inactive conversation becoming active again isn't treated as new.
+func (hn *HostexNetworkAPI) loadSyncCursor(ctx context.Context, conversationID string) {
+	if hn.state.cursorsLoaded(conversationID) {
+		return
+	}
+	cursor, err := hn.connector.DB.SyncCursor.Get(ctx, hn.login.ID, conversationID)
+	if err != nil {
+		hn.br.Log.Error().Err(err).Str("conversation_id", conversationID).Msg("Failed to load sync cursor")
+	} else if cursor != nil {
+		hn.state.restoreCursors(conversationID, cursor.LastMessageAt, cursor.LastProcessedAt)
+	} else {
+		// Not synced before, remember that so the database isn't checked again
+		hn.state.restoreCursors(conversationID, time.Time{}, time.Time{})
+	}
+}
+
+// saveSyncCursor persists the sync cursors of a conversation to the database.
+func (hn *HostexNetworkAPI) saveSyncCursor(ctx context.Context, conversationID string, lastMessageAt, lastProcessedAt time.Time) {
+	err := hn.connector.DB.SyncCursor.Put(ctx, &hostexdb.SyncCursor{
+		LoginID:         hn.login.ID,
+		ConversationID:  conversationID,
+		LastMessageAt:   lastMessageAt,
+		LastProcessedAt: lastProcessedAt,
+	})
+	if err != nil {
+		hn.br.Log.Error().Err(err).Str("conversation_id", conversationID).Msg("Failed to save sync cursor")
 	}
 }
 
-func (hn *HostexNetworkAPI) processConversation(ctx context.Context, conv hostexapi.Conversation) {
+func (hn *HostexNetworkAPI) processConversation(ctx context.Context, conv hostexapi.Conversation, details *hostexapi.ConversationDetails) {
 	// Create portal key for this conversation
 	portalKey := networkid.PortalKey{
 		ID:       networkid.PortalID(conv.ID),
@@ -542,15 +936,6 @@ func (hn *HostexNetworkAPI) processConversation(ctx context.Context, conv hostex
 		Str("portal_key", portalKey.String()).
 		Msg("Portal check result")
 
-	// Get conversation details to fetch property name and messages (only when needed)
-	hn.br.Log.Debug().Str("conversation_id", conv.ID).Msg("Fetching conversation details from Hostex API")
-	details, err := hn.client.GetConversationDetails(ctx, conv.ID)
-	if err != nil {
-		hn.br.Log.Error().Err(err).Str("conversation_id", conv.ID).Msg("Failed to get conversation details")
-		return
-	}
-	hn.br.Log.Debug().Str("conversation_id", conv.ID).Int("message_count", len(details.Messages)).Msg("Got conversation details from Hostex API")
-
 	// Get property name from activities (use first activity with a property)
 	propertyName := "Unknown Property"
 	if len(details.Activities) > 0 && details.Activities[0].Property.Title != "" {
@@ -558,47 +943,74 @@ func (hn *HostexNetworkAPI) processConversation(ctx context.Context, conv hostex
 	}
 
 	// Store guest name for later use
-	hn.guestNames[conv.ID] = conv.Guest.Name
+	hn.state.setGuestName(conv.ID, conv.Guest.Name)
 
 	// Create room name with format "(Property) - Guest Name"
 	roomName := fmt.Sprintf("(%s) - %s", propertyName, conv.Guest.Name)
 
+	var batch eventBatch
 	if err != nil || portal == nil || portal.MXID == "" {
 		hn.br.Log.Info().Str("conversation_id", conv.ID).Str("guest_name", conv.Guest.Name).Msg("Creating Matrix room for conversation with backfill")
 
-		// Send a chat info change event to trigger Matrix room creation
 		chatInfo := &bridgev2.ChatInfo{
 			Name:  &roomName,
 			Topic: &propertyName,
 		}
-
-		// Create a remote event to trigger portal and Matrix room creation
-		//nolint:staticcheck // Using deprecated API until new simplevent API is properly documented
-		remoteEvent := &bridgev2.SimpleRemoteEvent[*bridgev2.ChatInfoChange]{
-			Type:         bridgev2.RemoteEventChatInfoChange,
-			PortalKey:    portalKey,
-			CreatePortal: true,
-			Timestamp:    conv.LastMessageAt,
-			LogContext: func(c zerolog.Context) zerolog.Context {
-				return c.Str("guest_name", conv.Guest.Name).Str("property_name", propertyName)
-			},
-			Sender: bridgev2.EventSender{
-				IsFromMe: false,
-				Sender:   networkid.UserID("guest_" + conv.ID),
-			},
-			ChatInfoChange: &bridgev2.ChatInfoChange{
-				ChatInfo: chatInfo,
-			},
+		guestSender := bridgev2.EventSender{
+			IsFromMe: false,
+			Sender:   networkid.UserID("guest_" + conv.ID),
+		}
+		logContext := func(c zerolog.Context) zerolog.Context {
+			return c.Str("guest_name", conv.Guest.Name).Str("property_name", propertyName)
 		}
 
-		// Send the remote event to create the Matrix room
-		hn.br.QueueRemoteEvent(hn.login, remoteEvent)
+		if hn.br.Config.Backfill.Enabled {
+			// Create the room with a resync and let the bridge backfill the history through
+			// FetchMessages, reusing the details that were just fetched for the first batch
+			resyncEvent := &simplevent.ChatResync{
+				EventMeta: simplevent.EventMeta{
+					Type:         bridgev2.RemoteEventChatResync,
+					PortalKey:    portalKey,
+					CreatePortal: true,
+					Timestamp:    conv.LastMessageAt,
+					Sender:       guestSender,
+					LogContext:   logContext,
+				},
+				ChatInfo:            chatInfo,
+				LatestMessageTS:     conv.LastMessageAt,
+				BundledBackfillData: details,
+			}
+			batch.add(resyncEvent, &resyncEvent.EventMeta)
+		} else {
+			// Send a chat info change event to trigger Matrix room creation
+			infoEvent := &simplevent.ChatInfoChange{
+				EventMeta: simplevent.EventMeta{
+					Type:         bridgev2.RemoteEventChatInfoChange,
+					PortalKey:    portalKey,
+					CreatePortal: true,
+					Timestamp:    conv.LastMessageAt,
+					LogContext:   logContext,
+					Sender:       guestSender,
+				},
+				ChatInfoChange: &bridgev2.ChatInfoChange{
+					ChatInfo: chatInfo,
+				},
+			}
+			batch.add(infoEvent, &infoEvent.EventMeta)
+
+			// Without backfill, bridge the history as regular messages
+			hn.br.Log.Debug().Int("message_count", len(details.Messages)).Msg("Queueing messages for new portal")
+			for i := len(details.Messages) - 1; i >= 0; i-- {
+				msg := details.Messages[i]
+				if messageEvent := hn.newMessageEvent(ctx, portalKey, &msg, conv.ID); messageEvent != nil {
+					batch.add(messageEvent, &messageEvent.EventMeta)
+				}
+			}
+		}
 
-		// Queue message backfill events for new rooms
-		hn.br.Log.Debug().Int("message_count", len(details.Messages)).Msg("Queueing messages for new portal")
-		for i := len(details.Messages) - 1; i >= 0; i-- {
-			msg := details.Messages[i]
-			hn.queueMessageEvent(ctx, portalKey, &msg, conv.ID, conv.Guest.Name)
+		// Everything has been queued, so later polls only need messages after the newest one
+		if len(details.Messages) > 0 {
+			hn.state.setLastProcessedAt(conv.ID, details.Messages[0].CreatedAt) // Messages are in reverse chronological order
 		}
 
 		hn.br.Log.Info().
@@ -619,18 +1031,19 @@ func (hn *HostexNetworkAPI) processConversation(ctx context.Context, conv hostex
 			Topic: &propertyName,
 		}
 
-		//nolint:staticcheck // Using deprecated API until new simplevent API is properly documented
-		chatInfoEvent := &bridgev2.SimpleRemoteEvent[*bridgev2.ChatInfoChange]{
-			Type:         bridgev2.RemoteEventChatInfoChange,
-			PortalKey:    portalKey,
-			CreatePortal: false, // Don't create, just update
-			Timestamp:    conv.LastMessageAt,
-			LogContext: func(c zerolog.Context) zerolog.Context {
-				return c.Str("guest_name", conv.Guest.Name).Str("property_name", propertyName)
-			},
-			Sender: bridgev2.EventSender{
-				IsFromMe: false,
-				Sender:   networkid.UserID("guest_" + conv.ID),
+		chatInfoEvent := &simplevent.ChatInfoChange{
+			EventMeta: simplevent.EventMeta{
+				Type:         bridgev2.RemoteEventChatInfoChange,
+				PortalKey:    portalKey,
+				CreatePortal: false, // Don't create, just update
+				Timestamp:    conv.LastMessageAt,
+				LogContext: func(c zerolog.Context) zerolog.Context {
+					return c.Str("guest_name", conv.Guest.Name).Str("property_name", propertyName)
+				},
+				Sender: bridgev2.EventSender{
+					IsFromMe: false,
+					Sender:   networkid.UserID("guest_" + conv.ID),
+				},
 			},
 			ChatInfoChange: &bridgev2.ChatInfoChange{
 				ChatInfo: chatInfo,
@@ -638,20 +1051,16 @@ func (hn *HostexNetworkAPI) processConversation(ctx context.Context, conv hostex
 		}
 
 		// Update the room info
-		hn.br.QueueRemoteEvent(hn.login, chatInfoEvent)
+		batch.add(chatInfoEvent, &chatInfoEvent.EventMeta)
 
 		// For existing rooms, only queue messages that are newer than the last processed message
-		hn.lastMessageTimeMu.RLock()
-		lastProcessedTime, hasLastTime := hn.lastMessageTime[conv.ID]
-		hn.lastMessageTimeMu.RUnlock()
+		_, lastProcessedTime := hn.state.cursors(conv.ID)
 
-		if !hasLastTime {
+		if lastProcessedTime.IsZero() {
 			// First time seeing this conversation, set baseline to oldest message to avoid flooding
 			if len(details.Messages) > 0 {
 				oldestMsg := details.Messages[len(details.Messages)-1] // Messages are in reverse chronological order
-				hn.lastMessageTimeMu.Lock()
-				hn.lastMessageTime[conv.ID] = oldestMsg.CreatedAt
-				hn.lastMessageTimeMu.Unlock()
+				hn.state.setLastProcessedAt(conv.ID, oldestMsg.CreatedAt)
 				lastProcessedTime = oldestMsg.CreatedAt
 			}
 		}
@@ -673,22 +1082,22 @@ func (hn *HostexNetworkAPI) processConversation(ctx context.Context, conv hostex
 				hn.br.Log.Info().
 					Str("conversation_id", conv.ID).
 					Str("message_id", msg.ID).
-					Str("content", msg.Content).
+					Str("content", hn.connector.Config.redact(msg.Content)).
 					Str("sender_role", msg.SenderRole).
 					Str("created_at", msg.CreatedAt.String()).
 					Str("last_processed", lastProcessedTime.String()).
 					Msg("Found new message to queue")
 
-				hn.queueMessageEvent(ctx, portalKey, &msg, conv.ID, conv.Guest.Name)
+				if messageEvent := hn.newMessageEvent(ctx, portalKey, &msg, conv.ID); messageEvent != nil {
+					batch.add(messageEvent, &messageEvent.EventMeta)
+				}
 				newMessageCount++
 			}
 		}
 
 		// Update the last processed time
 		if latestMessageTime.After(lastProcessedTime) {
-			hn.lastMessageTimeMu.Lock()
-			hn.lastMessageTime[conv.ID] = latestMessageTime
-			hn.lastMessageTimeMu.Unlock()
+			hn.state.setLastProcessedAt(conv.ID, latestMessageTime)
 		}
 
 		hn.br.Log.Info().
@@ -698,351 +1107,117 @@ func (hn *HostexNetworkAPI) processConversation(ctx context.Context, conv hostex
 			Str("latest_message_time", latestMessageTime.String()).
 			Msg("Processed existing portal for new messages")
 	}
+
+	// Queueing only hands the events to the portal, so the cursors are persisted once the
+	// portal has handled the last one. If the bridge stops before that, the messages are
+	// fetched again on the next start and the bridge drops the ones it already has.
+	_, lastProcessedAt := hn.state.cursors(conv.ID)
+	lastMessageAt := conv.LastMessageAt
+	batch.last.PostHandleFunc = func(ctx context.Context, _ *bridgev2.Portal) {
+		hn.saveSyncCursor(ctx, conv.ID, lastMessageAt, lastProcessedAt)
+	}
+	for _, evt := range batch.events {
+		hn.br.QueueRemoteEvent(hn.login, evt)
+	}
 }
 
-func (hn *HostexNetworkAPI) queueMessageEvent(ctx context.Context, portalKey networkid.PortalKey, msg *hostexapi.Message, conversationID string, guestName string) {
-	// Check if this is a host message that was recently sent from Matrix (to prevent echo)
-	if msg.SenderRole == "host" {
-		hn.sentMessagesMu.RLock()
-		if sentTime, exists := hn.sentMessages[msg.Content]; exists {
-			// If message was sent within the last 2 minutes, skip it
-			if time.Since(sentTime) < 2*time.Minute {
-				hn.sentMessagesMu.RUnlock()
-				hn.br.Log.Debug().
-					Str("content", msg.Content).
-					Str("message_id", msg.ID).
-					Msg("Skipping echo of recently sent message")
-				return
-			}
-		}
-		hn.sentMessagesMu.RUnlock()
+// eventBatch collects the remote events of a conversation sync, so a post-handle function can
+// be attached to the last one before anything is queued.
+type eventBatch struct {
+	events []bridgev2.RemoteEvent
+	last   *simplevent.EventMeta
+}
+
+func (b *eventBatch) add(evt bridgev2.RemoteEvent, meta *simplevent.EventMeta) {
+	b.events = append(b.events, evt)
+	b.last = meta
+}
+
+// newMessageEvent builds the remote event for a Hostex message, or returns nil if the message
+// is the echo of one sent from Matrix.
+func (hn *HostexNetworkAPI) newMessageEvent(ctx context.Context, portalKey networkid.PortalKey, msg *hostexapi.Message, conversationID string) *simplevent.Message[*hostexapi.Message] {
+	// Check if this is the echo of a message sent from Matrix
+	if hn.reconcileEcho(ctx, conversationID, msg) {
+		hn.br.Log.Debug().
+			Str("conversation_id", conversationID).
+			Str("message_id", msg.ID).
+			Msg("Skipping echo of message sent from Matrix")
+		return nil
 	}
 
-	// Determine sender
-	var senderID networkid.UserID
-	var isFromMe bool
+	return &simplevent.Message[*hostexapi.Message]{
+		EventMeta: simplevent.EventMeta{
+			Type:      bridgev2.RemoteEventMessage,
+			PortalKey: portalKey,
+			Timestamp: msg.CreatedAt,
+			LogContext: func(c zerolog.Context) zerolog.Context {
+				return c.Str("message_id", msg.ID).Str("sender_role", msg.SenderRole)
+			},
+			Sender: hn.messageSender(msg, conversationID),
+		},
+		ID:                 networkid.MessageID(msg.ID),
+		Data:               msg,
+		ConvertMessageFunc: hn.convertMessage,
+	}
+}
 
+// messageSender returns the sender of a Hostex message. Host messages use double puppeting
+// to show as sent by the actual Matrix user.
+func (hn *HostexNetworkAPI) messageSender(msg *hostexapi.Message, conversationID string) bridgev2.EventSender {
 	if msg.SenderRole == "host" {
-		// Host message - use double puppeting to show as sent by the actual Matrix user
-		senderID = networkid.UserID("host_" + string(hn.login.ID))
-		isFromMe = true
-	} else {
-		// Guest message
-		senderID = networkid.UserID("guest_" + conversationID)
-		isFromMe = false
-	}
-
-	// Create message event
-	//nolint:staticcheck // Using deprecated API until new simplevent API is properly documented
-	messageEvent := &bridgev2.SimpleRemoteEvent[*hostexapi.Message]{
-		Type:      bridgev2.RemoteEventMessage,
-		PortalKey: portalKey,
-		ID:        networkid.MessageID(msg.ID),
-		Timestamp: msg.CreatedAt,
-		LogContext: func(c zerolog.Context) zerolog.Context {
-			return c.Str("message_id", msg.ID).Str("sender_role", msg.SenderRole)
-		},
-		Sender: bridgev2.EventSender{
-			IsFromMe: isFromMe,
-			SenderLogin: func() networkid.UserLoginID {
-				if isFromMe {
-					return hn.login.ID
-				}
-				return ""
-			}(),
-			Sender: senderID,
-		},
-		Data: msg,
-		ConvertMessageFunc: func(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, data *hostexapi.Message) (*bridgev2.ConvertedMessage, error) {
-			parts := []*bridgev2.ConvertedMessagePart{}
-
-			// Handle text content
-			if data.Content != "" {
-				parts = append(parts, &bridgev2.ConvertedMessagePart{
-					Type: event.EventMessage,
-					Content: &event.MessageEventContent{
-						MsgType: event.MsgText,
-						Body:    data.Content,
-					},
-				})
-			}
+		return bridgev2.EventSender{
+			IsFromMe:    true,
+			SenderLogin: hn.login.ID,
+			Sender:      networkid.UserID("host_" + string(hn.login.ID)),
+		}
+	}
+	return bridgev2.EventSender{
+		Sender: networkid.UserID("guest_" + conversationID),
+	}
+}
 
-			// Handle attachments (images, files, etc.)
-			if data.Attachment != nil {
-				// Debug: Log attachment data structure and type
-				attachmentType := fmt.Sprintf("%T", data.Attachment)
-				portal.Bridge.Log.Debug().Interface("attachment", data.Attachment).Str("message_id", data.ID).Str("attachment_type", attachmentType).Msg("Processing attachment")
-
-				var attachmentURL, filename, mimeType string
-				var processed bool
-
-				// Try to parse attachment as an object
-				attachmentBytes, err := json.Marshal(data.Attachment)
-				if err == nil {
-					var attachmentObj map[string]interface{}
-					if err := json.Unmarshal(attachmentBytes, &attachmentObj); err == nil {
-						portal.Bridge.Log.Debug().Interface("parsed_attachment", attachmentObj).Str("message_id", data.ID).Msg("Successfully parsed attachment as object")
-
-						// Handle image attachments - try multiple URL field names (Hostex uses "fullback_url")
-						for _, urlField := range []string{"fullback_url", "url", "URL", "src", "href", "link"} {
-							if url, ok := attachmentObj[urlField].(string); ok && url != "" {
-								attachmentURL = url
-								break
-							}
-						}
-
-						if attachmentURL != "" {
-							// Try to get filename - check multiple field names or generate from URL
-							filename = "attachment"
-							for _, nameField := range []string{"filename", "name", "title"} {
-								if name, ok := attachmentObj[nameField].(string); name != "" && ok {
-									filename = name
-									break
-								}
-							}
-
-							// If no filename found, try to extract from URL
-							if filename == "attachment" && attachmentURL != "" {
-								if lastSlash := strings.LastIndex(attachmentURL, "/"); lastSlash != -1 {
-									urlFilename := attachmentURL[lastSlash+1:]
-									if urlFilename != "" && !strings.Contains(urlFilename, "?") {
-										filename = urlFilename
-									} else if strings.Contains(urlFilename, "?") {
-										// Extract filename before query parameters
-										if qIndex := strings.Index(urlFilename, "?"); qIndex != -1 {
-											filename = urlFilename[:qIndex]
-										}
-									}
-								}
-								// For Hostex images ending in /xlarge, use a better filename
-								if filename == "xlarge" || filename == "large" || filename == "medium" || filename == "small" {
-									// Extract actual filename from the path before the size modifier
-									if strings.Contains(attachmentURL, ".jpeg/") || strings.Contains(attachmentURL, ".jpg/") {
-										// URL format: .../RQX1754769570578.jpeg/xlarge
-										parts := strings.Split(attachmentURL, "/")
-										if len(parts) >= 2 {
-											for i := len(parts) - 2; i >= 0; i-- {
-												if strings.Contains(parts[i], ".") {
-													filename = parts[i]
-													break
-												}
-											}
-										}
-									}
-									// If still a size name, use a generic image filename
-									if filename == "xlarge" || filename == "large" || filename == "medium" || filename == "small" {
-										filename = "image.jpg"
-									}
-								}
-							}
-
-							// Try to get mime type from attachment object
-							for _, typeField := range []string{"type", "mime_type", "mimeType", "content_type"} {
-								if attachType, ok := attachmentObj[typeField].(string); ok && attachType != "" {
-									// Convert Hostex "image" type to proper MIME type
-									if attachType == "image" {
-										mimeType = "image/jpeg" // Default for Hostex images
-									} else {
-										mimeType = attachType
-									}
-									break
-								}
-							}
-
-							portal.Bridge.Log.Debug().Str("attachment_url", attachmentURL).Str("filename", filename).Str("mime_type", mimeType).Str("message_id", data.ID).Msg("Extracted attachment details")
-
-							// Download the attachment
-							resp, err := http.Get(attachmentURL)
-							if err != nil {
-								parts = append(parts, &bridgev2.ConvertedMessagePart{
-									Type: event.EventMessage,
-									Content: &event.MessageEventContent{
-										MsgType: event.MsgText,
-										Body:    fmt.Sprintf("📎 %s: %s (download failed)", filename, attachmentURL),
-									},
-								})
-							} else {
-								defer resp.Body.Close()
-								imageData, err := io.ReadAll(resp.Body)
-								if err != nil {
-									parts = append(parts, &bridgev2.ConvertedMessagePart{
-										Type: event.EventMessage,
-										Content: &event.MessageEventContent{
-											MsgType: event.MsgText,
-											Body:    fmt.Sprintf("📎 %s: %s (read failed)", filename, attachmentURL),
-										},
-									})
-								} else {
-									// Upload to Matrix
-									responseMimeType := resp.Header.Get("Content-Type")
-									if responseMimeType != "" {
-										mimeType = responseMimeType
-									} else if mimeType == "" {
-										mimeType = "application/octet-stream"
-									}
-
-									// Determine message type based on MIME type
-									msgType := event.MsgFile
-									if strings.HasPrefix(mimeType, "image/") {
-										msgType = event.MsgImage
-									}
-
-									portal.Bridge.Log.Debug().Int("image_size", len(imageData)).Str("filename", filename).Str("mime_type", mimeType).Msg("Uploading image to Matrix")
-									mxcURL, uploadInfo, err := intent.UploadMedia(ctx, portal.MXID, imageData, filename, mimeType)
-									if err != nil {
-										portal.Bridge.Log.Error().Err(err).Str("filename", filename).Int("size", len(imageData)).Msg("Failed to upload image to Matrix")
-										parts = append(parts, &bridgev2.ConvertedMessagePart{
-											Type: event.EventMessage,
-											Content: &event.MessageEventContent{
-												MsgType: event.MsgText,
-												Body:    fmt.Sprintf("📎 %s: %s (upload failed: %v)", filename, attachmentURL, err),
-											},
-										})
-									} else if mxcURL == "" {
-										portal.Bridge.Log.Error().Str("filename", filename).Interface("upload_info", uploadInfo).Msg("Matrix upload returned empty mxcURL")
-										parts = append(parts, &bridgev2.ConvertedMessagePart{
-											Type: event.EventMessage,
-											Content: &event.MessageEventContent{
-												MsgType: event.MsgText,
-												Body:    fmt.Sprintf("📎 %s: %s (Matrix upload returned empty URL)", filename, attachmentURL),
-											},
-										})
-									} else {
-										parts = append(parts, &bridgev2.ConvertedMessagePart{
-											Type: event.EventMessage,
-											Content: &event.MessageEventContent{
-												MsgType: msgType,
-												Body:    filename,
-												URL:     id.ContentURIString(string(mxcURL)),
-											},
-										})
-										processed = true
-										portal.Bridge.Log.Info().Str("mxc_url", string(mxcURL)).Str("filename", filename).Str("mime_type", mimeType).Str("message_id", data.ID).Msg("Successfully uploaded attachment to Matrix")
-									}
-								}
-							}
-						}
-					} else {
-						portal.Bridge.Log.Debug().Str("message_id", data.ID).Str("error", err.Error()).Msg("Failed to unmarshal attachment as object")
-					}
-				} else {
-					portal.Bridge.Log.Debug().Str("message_id", data.ID).Str("error", err.Error()).Msg("Failed to marshal attachment for parsing")
-				}
+// convertMessage converts a Hostex message to Matrix, uploading its attachment if it has one.
+func (hn *HostexNetworkAPI) convertMessage(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, data *hostexapi.Message) (*bridgev2.ConvertedMessage, error) {
+	parts := []*bridgev2.ConvertedMessagePart{}
 
-				// If object parsing failed, try string parsing
-				if !processed {
-					// Handle attachment as string (URL)
-					if attachmentStr, ok := data.Attachment.(string); ok && attachmentStr != "" {
-						portal.Bridge.Log.Debug().Str("attachment_string", attachmentStr).Str("message_id", data.ID).Msg("Processing attachment as string URL")
-
-						// Download the attachment
-						resp, err := http.Get(attachmentStr)
-						if err != nil {
-							parts = append(parts, &bridgev2.ConvertedMessagePart{
-								Type: event.EventMessage,
-								Content: &event.MessageEventContent{
-									MsgType: event.MsgText,
-									Body:    fmt.Sprintf("📎 Image: %s (download failed)", attachmentStr),
-								},
-							})
-						} else {
-							defer resp.Body.Close()
-							imageData, err := io.ReadAll(resp.Body)
-							if err != nil {
-								parts = append(parts, &bridgev2.ConvertedMessagePart{
-									Type: event.EventMessage,
-									Content: &event.MessageEventContent{
-										MsgType: event.MsgText,
-										Body:    fmt.Sprintf("📎 Image: %s (read failed)", attachmentStr),
-									},
-								})
-							} else {
-								// Upload to Matrix
-								mimeType := resp.Header.Get("Content-Type")
-								if mimeType == "" {
-									mimeType = "application/octet-stream"
-								}
-
-								// Determine message type and filename based on MIME type
-								msgType := event.MsgFile
-								filename := "attachment"
-								if strings.HasPrefix(mimeType, "image/") {
-									msgType = event.MsgImage
-									// Set appropriate filename extension based on MIME type
-									switch mimeType {
-									case "image/jpeg":
-										filename = "image.jpg"
-									case "image/png":
-										filename = "image.png"
-									case "image/gif":
-										filename = "image.gif"
-									case "image/webp":
-										filename = "image.webp"
-									default:
-										filename = "image"
-									}
-								}
-
-								portal.Bridge.Log.Debug().Int("image_size", len(imageData)).Str("filename", filename).Str("mime_type", mimeType).Msg("Uploading string attachment image to Matrix")
-								mxcURL, uploadInfo, err := intent.UploadMedia(ctx, portal.MXID, imageData, filename, mimeType)
-								if err != nil {
-									portal.Bridge.Log.Error().Err(err).Str("filename", filename).Int("size", len(imageData)).Msg("Failed to upload string attachment to Matrix")
-									parts = append(parts, &bridgev2.ConvertedMessagePart{
-										Type: event.EventMessage,
-										Content: &event.MessageEventContent{
-											MsgType: event.MsgText,
-											Body:    fmt.Sprintf("📎 %s: %s (upload failed: %v)", filename, attachmentStr, err),
-										},
-									})
-								} else if mxcURL == "" {
-									portal.Bridge.Log.Error().Str("filename", filename).Interface("upload_info", uploadInfo).Msg("Matrix upload returned empty mxcURL for string attachment")
-									parts = append(parts, &bridgev2.ConvertedMessagePart{
-										Type: event.EventMessage,
-										Content: &event.MessageEventContent{
-											MsgType: event.MsgText,
-											Body:    fmt.Sprintf("📎 %s: %s (Matrix upload returned empty URL)", filename, attachmentStr),
-										},
-									})
-								} else {
-									parts = append(parts, &bridgev2.ConvertedMessagePart{
-										Type: event.EventMessage,
-										Content: &event.MessageEventContent{
-											MsgType: msgType,
-											Body:    filename,
-											URL:     id.ContentURIString(string(mxcURL)),
-										},
-									})
-									portal.Bridge.Log.Info().Str("mxc_url", string(mxcURL)).Str("filename", filename).Str("mime_type", mimeType).Str("message_id", data.ID).Msg("Successfully uploaded string attachment to Matrix")
-								}
-							}
-						}
-					} else {
-						// Log unhandled attachment types
-						portal.Bridge.Log.Debug().Str("message_id", data.ID).Interface("attachment", data.Attachment).Str("attachment_type", attachmentType).Msg("Unhandled attachment type - not a string or parseable object")
-					}
-				}
-			}
+	// Thread messages that quote an earlier message back to it as a Matrix reply
+	replyTo, text := hn.findQuotedReply(ctx, portal, data.Content)
 
-			// If no parts were created, add a default text message
-			if len(parts) == 0 {
-				portal.Bridge.Log.Debug().Str("message_id", data.ID).Str("content", data.Content).Interface("attachment", data.Attachment).Msg("No message parts created, falling back to empty message")
-				parts = append(parts, &bridgev2.ConvertedMessagePart{
-					Type: event.EventMessage,
-					Content: &event.MessageEventContent{
-						MsgType: event.MsgText,
-						Body:    "(Empty message)",
-					},
-				})
-			}
+	// Handle text content
+	if text != "" {
+		parts = append(parts, &bridgev2.ConvertedMessagePart{
+			Type: event.EventMessage,
+			Content: &event.MessageEventContent{
+				MsgType: event.MsgText,
+				Body:    text,
+			},
+		})
+	}
 
-			return &bridgev2.ConvertedMessage{
-				Parts: parts,
-			}, nil
-		},
+	// Handle attachments (images, files, etc.)
+	if data.HasAttachment() {
+		parts = append(parts, hn.convertAttachment(ctx, portal, intent, data))
 	}
 
-	// Queue the message event
-	hn.br.QueueRemoteEvent(hn.login, messageEvent)
+	// If no parts were created, add a default text message
+	if len(parts) == 0 {
+		portal.Bridge.Log.Debug().Str("message_id", data.ID).Msg("No message parts created, falling back to empty message")
+		parts = append(parts, &bridgev2.ConvertedMessagePart{
+			Type: event.EventMessage,
+			Content: &event.MessageEventContent{
+				MsgType: event.MsgText,
+				Body:    "(Empty message)",
+			},
+		})
+	}
+
+	// Remember the text so that replies from Matrix can quote this message
+	parts[0].DBMetadata = &HostexMessageMetadata{Text: text}
+
+	return &bridgev2.ConvertedMessage{
+		ReplyTo: replyTo,
+		Parts:   parts,
+	}, nil
 }
 
 // sendStartupNotification sends a message to the admin user when the bridge starts
@@ -1144,12 +1319,7 @@ func (hc *HostexConnector) handleRefreshCommand(ce *commands.Event) {
 		if login.Client != nil {
 			if hostexAPI, ok := login.Client.(*HostexNetworkAPI); ok {
 				// Clear the conversation last message cache to force re-check
-				hostexAPI.conversationLastMsgMu.Lock()
-				// Clear the cache
-				for k := range hostexAPI.conversationLastMsgTime {
-					delete(hostexAPI.conversationLastMsgTime, k)
-				}
-				hostexAPI.conversationLastMsgMu.Unlock()
+				hostexAPI.state.clearLastMessageAt()
 
 				// Run sync which will now re-process all conversations
 				go hostexAPI.syncConversations(ce.Ctx)
@@ -1186,34 +1356,6 @@ func (hc *HostexConnector) handleCleanupCommand(ce *commands.Event) {
 	ce.Reply("✅ Room cleanup and re-backfill initiated. Room names will be updated and messages re-processed with double puppeting and attachment support.")
 }
 
-// handleWebhook handles incoming webhooks from Hostex
-func (hc *HostexConnector) handleWebhook(w http.ResponseWriter, r *http.Request) {
-	hc.br.Log.Info().Str("method", r.Method).Str("path", r.URL.Path).Msg("Received webhook")
-
-	// Read the request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		hc.br.Log.Error().Err(err).Msg("Failed to read webhook body")
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-
-	// Log the webhook data for debugging
-	hc.br.Log.Debug().Str("body", string(body)).Msg("Webhook payload received")
-
-	// TODO: Parse webhook data and trigger appropriate bridge actions
-	// For now, just acknowledge receipt
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "received",
-		"bridge": "mautrix-hostex",
-	}); err != nil {
-		hc.br.Log.Error().Err(err).Msg("Failed to encode webhook response")
-	}
-}
-
 // handleHealth handles health check requests
 func (hc *HostexConnector) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -1222,6 +1364,7 @@ func (hc *HostexConnector) handleHealth(w http.ResponseWriter, r *http.Request)
 		"status":    "healthy",
 		"bridge":    "mautrix-hostex",
 		"timestamp": time.Now().Format(time.RFC3339),
+		"webhooks":  hc.webhookMetrics.snapshot(),
 	}); err != nil {
 		hc.br.Log.Error().Err(err).Msg("Failed to encode health check response")
 	}
@@ -0,0 +1,103 @@
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hostex-matrix-bridge/pkg/hostexapi"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/bridgev2/networkid"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	// How long a message sent from Matrix waits to be found in the Hostex history
+	pendingMessageTTL = 15 * time.Minute
+)
+
+// pendingMessage is a message sent from Matrix that hasn't been seen in the Hostex conversation history yet.
+type pendingMessage struct {
+	MXID        id.EventID
+	MessageID   networkid.MessageID // ID the message was saved with, replaced once the Hostex message is found
+	ContentHash string
+	HasImage    bool
+	SentAt      time.Time
+}
+
+func hashMessageContent(content string) string {
+	normalized := strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+// prunePendingMessages drops pending messages that were never found in the history.
+func prunePendingMessages(pending []*pendingMessage, now time.Time) []*pendingMessage {
+	kept := pending[:0]
+	for _, msg := range pending {
+		if now.Sub(msg.SentAt) < pendingMessageTTL {
+			kept = append(kept, msg)
+		}
+	}
+	return kept
+}
+
+// findPendingMessage finds the pending message that a message from the Hostex history is the echo of.
+// A message matches if it already has the same ID, or if it's from the host with the same content
+// and attachment presence, sent around the same time. The oldest matching message wins.
+func findPendingMessage(pending []*pendingMessage, msg *hostexapi.Message) int {
+	if msg.SenderRole != "host" {
+		return -1
+	}
+	contentHash := hashMessageContent(msg.Content)
+	hasImage := msg.HasAttachment()
+	for i, candidate := range pending {
+		if candidate.MessageID != networkid.MessageID(msg.ID) {
+			if candidate.ContentHash != contentHash || candidate.HasImage != hasImage {
+				continue
+			}
+			if diff := msg.CreatedAt.Sub(candidate.SentAt); diff > hostexapi.MaxClockSkew || diff < -hostexapi.MaxClockSkew {
+				continue
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+// reconcileEcho checks whether a host message from Hostex is the echo of a message sent from Matrix.
+// If it is, the database row of the sent message is pointed at the real Hostex message ID and
+// true is returned so that the message isn't bridged a second time.
+func (hn *HostexNetworkAPI) reconcileEcho(ctx context.Context, conversationID string, msg *hostexapi.Message) bool {
+	pending := hn.state.matchPendingMessage(conversationID, msg)
+	if pending == nil {
+		return false
+	}
+	log := hn.br.Log.With().
+		Str("conversation_id", conversationID).
+		Str("message_id", msg.ID).
+		Stringer("event_id", pending.MXID).
+		Logger()
+	if pending.MessageID == networkid.MessageID(msg.ID) {
+		log.Debug().Msg("Sent message already has its Hostex ID")
+		return true
+	}
+
+	dbMessage, err := hn.br.DB.Message.GetPartByMXID(ctx, pending.MXID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get sent message from database")
+		return true
+	} else if dbMessage == nil {
+		log.Warn().Msg("Sent message not found in database, can't remap its ID")
+		return true
+	}
+	dbMessage.ID = networkid.MessageID(msg.ID)
+	dbMessage.Timestamp = msg.CreatedAt
+	if err := hn.br.DB.Message.Update(ctx, dbMessage); err != nil {
+		log.Err(err).Msg("Failed to remap sent message to Hostex message ID")
+	} else {
+		log.Debug().Str("previous_message_id", string(pending.MessageID)).Msg("Remapped sent message to Hostex message ID")
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package connector
+
+import (
+	"testing"
+	"time"
+
+	"maunium.net/go/mautrix/bridgev2/networkid"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+func TestFindPendingMessage(t *testing.T) {
+	sentAt := time.Unix(1700000000, 0)
+	pending := []*pendingMessage{
+		{MessageID: "pending-1", ContentHash: hashMessageContent("Hello"), SentAt: sentAt},
+		{MessageID: "pending-2", ContentHash: hashMessageContent("Hello"), SentAt: sentAt.Add(30 * time.Second)},
+		{MessageID: "pending-3", ContentHash: hashMessageContent("Photo"), HasImage: true, SentAt: sentAt},
+		{MessageID: "msg-known", ContentHash: hashMessageContent("Edited later"), SentAt: sentAt},
+	}
+	image := &hostexapi.Attachment{URL: "https://example.com/photo.jpg"}
+	tests := []struct {
+		name string
+		msg  hostexapi.Message
+		want int
+	}{
+		{"oldest matching content wins", hostexapi.Message{ID: "a", SenderRole: "host", Content: "Hello", CreatedAt: sentAt.Add(time.Minute)}, 0},
+		{"whitespace and line endings are ignored", hostexapi.Message{ID: "a", SenderRole: "host", Content: " Hello\r\n", CreatedAt: sentAt}, 0},
+		{"guest messages never match", hostexapi.Message{ID: "a", SenderRole: "guest", Content: "Hello", CreatedAt: sentAt}, -1},
+		{"different content", hostexapi.Message{ID: "a", SenderRole: "host", Content: "Bye", CreatedAt: sentAt}, -1},
+		{"outside clock skew", hostexapi.Message{ID: "a", SenderRole: "host", Content: "Hello", CreatedAt: sentAt.Add(-hostexapi.MaxClockSkew - time.Second)}, -1},
+		{"missing attachment", hostexapi.Message{ID: "a", SenderRole: "host", Content: "Photo", CreatedAt: sentAt}, -1},
+		{"with attachment", hostexapi.Message{ID: "a", SenderRole: "host", Content: "Photo", Attachment: image, CreatedAt: sentAt}, 2},
+		{"same ID matches regardless of content", hostexapi.Message{ID: "msg-known", SenderRole: "host", Content: "Something else", CreatedAt: sentAt.Add(time.Hour)}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findPendingMessage(pending, &tt.msg); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrunePendingMessages(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	pending := []*pendingMessage{
+		{MessageID: "old", SentAt: now.Add(-pendingMessageTTL - time.Second)},
+		{MessageID: "new", SentAt: now.Add(-time.Minute)},
+	}
+	kept := prunePendingMessages(pending, now)
+	if len(kept) != 1 || kept[0].MessageID != networkid.MessageID("new") {
+		t.Fatalf("got %v, want only the recent message", kept)
+	}
+}
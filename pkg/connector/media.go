@@ -0,0 +1,239 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/matrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+const (
+	// Hostex only accepts JPEG images for outgoing messages
+	hostexMaxImageSize      = 5 * 1024 * 1024
+	hostexMaxImageDimension = 2048
+
+	// Limits for images sent from Matrix before conversion. Decoding needs 4 bytes per pixel,
+	// so the pixel budget keeps a small file declaring huge dimensions from exhausting memory.
+	// It allows twice the Hostex dimension on each side, which covers common camera photos.
+	matrixMaxImageSize   = 25 * 1024 * 1024
+	matrixMaxImagePixels = 4 * hostexMaxImageDimension * hostexMaxImageDimension
+)
+
+var (
+	errImageTooLarge = fmt.Errorf("image dimensions exceed %d pixels", matrixMaxImagePixels)
+	errFileTooLarge  = errors.New("file is larger than the limit")
+)
+
+// hostexImageMimeTypes are the image formats that can be converted to JPEG for Hostex.
+var hostexImageMimeTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// convertImageForHostex decodes a JPEG, PNG, GIF or WebP image and re-encodes it as a JPEG
+// that fits within the Hostex size limits. Animated GIFs are reduced to their first frame.
+func convertImageForHostex(data []byte) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image header: %w", err)
+	} else if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > matrixMaxImagePixels {
+		return nil, errImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	maxDimension := hostexMaxImageDimension
+	for {
+		// Scale first so that flattening only copies the downscaled image
+		scaled := flattenImage(scaleImage(img, maxDimension))
+		for _, quality := range []int{90, 80, 70, 60} {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: quality}); err != nil {
+				return nil, fmt.Errorf("failed to encode jpeg: %w", err)
+			}
+			if buf.Len() <= hostexMaxImageSize {
+				return buf.Bytes(), nil
+			}
+		}
+		if maxDimension <= 256 {
+			return nil, fmt.Errorf("image is too large even after downscaling")
+		}
+		maxDimension /= 2
+	}
+}
+
+// flattenImage draws the image on a white background, as JPEG has no transparency.
+func flattenImage(img image.Image) image.Image {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+	return flat
+}
+
+// scaleImage downscales the image so that neither side exceeds maxDimension.
+func scaleImage(img image.Image, maxDimension int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxDimension && height <= maxDimension {
+		return img
+	}
+	if width >= height {
+		height = height * maxDimension / width
+		width = maxDimension
+	} else {
+		width = width * maxDimension / height
+		height = maxDimension
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, max(width, 1), max(height, 1)))
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, xdraw.Src, nil)
+	return scaled
+}
+
+// convertAttachment downloads a Hostex attachment and uploads it to Matrix. If that fails,
+// the attachment is bridged as a text message with its URL instead.
+func (hn *HostexNetworkAPI) convertAttachment(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, msg *hostexapi.Message) *bridgev2.ConvertedMessagePart {
+	attachment := msg.Attachment
+	attachmentURL := attachment.BestURL()
+	filename := attachment.GetFilename()
+	log := hn.br.Log.With().Str("message_id", msg.ID).Str("filename", filename).Logger()
+
+	data, mimeType, err := downloadAttachment(ctx, attachmentURL)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to download attachment")
+		return attachmentFallback(filename, attachmentURL, "download failed")
+	}
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = attachment.GetMimeType()
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	mxc, file, err := intent.UploadMedia(ctx, portal.MXID, data, filename, mimeType)
+	if err != nil {
+		log.Error().Err(err).Int("size", len(data)).Msg("Failed to upload attachment to Matrix")
+		return attachmentFallback(filename, attachmentURL, "upload failed")
+	}
+
+	msgType := event.MsgFile
+	if strings.HasPrefix(mimeType, "image/") {
+		msgType = event.MsgImage
+	}
+	log.Debug().Str("mime_type", mimeType).Int("size", len(data)).Msg("Uploaded attachment to Matrix")
+	return &bridgev2.ConvertedMessagePart{
+		Type: event.EventMessage,
+		Content: &event.MessageEventContent{
+			MsgType: msgType,
+			Body:    filename,
+			URL:     mxc,
+			File:    file,
+			Info: &event.FileInfo{
+				MimeType: mimeType,
+				Size:     len(data),
+				Width:    attachment.Width,
+				Height:   attachment.Height,
+			},
+		},
+	}
+}
+
+// downloadAttachment fetches an attachment from Hostex and returns its data and content type.
+func downloadAttachment(ctx context.Context, attachmentURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, attachmentURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response: %w", err)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// attachmentFallback is a text message linking to an attachment that couldn't be bridged.
+func attachmentFallback(filename, attachmentURL, reason string) *bridgev2.ConvertedMessagePart {
+	return &bridgev2.ConvertedMessagePart{
+		Type: event.EventMessage,
+		Content: &event.MessageEventContent{
+			MsgType: event.MsgText,
+			Body:    fmt.Sprintf("📎 %s: %s (%s)", filename, attachmentURL, reason),
+		},
+	}
+}
+
+// downloadMatrixMedia downloads a file from Matrix, giving up as soon as it turns out to be
+// larger than maxSize instead of reading all of it into memory first.
+func (hn *HostexNetworkAPI) downloadMatrixMedia(ctx context.Context, uri id.ContentURIString, file *event.EncryptedFileInfo, maxSize int64) ([]byte, error) {
+	intent, ok := hn.br.Bot.(*matrix.ASIntent)
+	if !ok {
+		// Other Matrix connectors only offer downloading the whole file
+		data, err := hn.br.Bot.DownloadMedia(ctx, uri, file)
+		if err == nil && int64(len(data)) > maxSize {
+			return nil, fmt.Errorf("%w: %d bytes", errFileTooLarge, len(data))
+		}
+		return data, err
+	}
+
+	if file != nil {
+		uri = file.URL
+		if err := file.PrepareForDecryption(); err != nil {
+			return nil, err
+		}
+	}
+	parsedURI, err := uri.Parse()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := intent.Matrix.Download(ctx, parsedURI)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", errFileTooLarge, resp.ContentLength)
+	}
+	// Read one byte past the limit to tell a file of exactly maxSize from a larger one
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	} else if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", errFileTooLarge, maxSize)
+	}
+	if file != nil {
+		if err := file.DecryptInPlace(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
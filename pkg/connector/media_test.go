@@ -0,0 +1,67 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withPNGDimensions rewrites the IHDR chunk of a PNG to declare different dimensions.
+func withPNGDimensions(data []byte, width, height uint32) []byte {
+	data = bytes.Clone(data)
+	// 8 byte signature, 4 byte length, then "IHDR" followed by width and height
+	ihdr := data[12 : 12+4+13]
+	binary.BigEndian.PutUint32(ihdr[4:8], width)
+	binary.BigEndian.PutUint32(ihdr[8:12], height)
+	binary.BigEndian.PutUint32(data[12+4+13:], crc32.ChecksumIEEE(ihdr))
+	return data
+}
+
+func TestConvertImageForHostexRejectsHugeDimensions(t *testing.T) {
+	data := withPNGDimensions(encodePNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1))), 40000, 40000)
+	_, err := convertImageForHostex(data)
+	if !errors.Is(err, errImageTooLarge) {
+		t.Fatalf("got %v, want errImageTooLarge", err)
+	}
+}
+
+func TestConvertImageForHostex(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3000, 1500))
+	for y := range 1500 {
+		for x := range 3000 {
+			// Left half is transparent, which must become white
+			if x >= 1500 {
+				src.Set(x, y, color.NRGBA{R: 200, A: 255})
+			}
+		}
+	}
+	out, err := convertImageForHostex(encodePNG(t, src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("output isn't a jpeg: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != hostexMaxImageDimension || bounds.Dy() != hostexMaxImageDimension/2 {
+		t.Errorf("got %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), hostexMaxImageDimension, hostexMaxImageDimension/2)
+	}
+	if r, g, b, _ := img.At(10, 10).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Errorf("transparent area wasn't flattened to white, got %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+}
@@ -0,0 +1,200 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/commands"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+// maxNightlyPrice is the highest nightly rate the price command accepts, to catch typos
+// before they're sent to every channel.
+const maxNightlyPrice = 1_000_000
+
+const priceUsage = "**Usage:**\n\n" +
+	"* `$cmdprefix price <property> <from> <to>` - show the nightly rates of a property\n" +
+	"* `$cmdprefix price <property> <from> <to> <amount>` - change the nightly rate on every channel\n\n" +
+	"Dates are YYYY-MM-DD and inclusive. The property can be its ID or part of its name."
+
+// handlePriceCommand handles the price command. Changing prices shows the current rates
+// first and is only applied after the user confirms.
+func (hc *HostexConnector) handlePriceCommand(ce *commands.Event) {
+	if len(ce.Args) < 2 {
+		ce.Reply(priceUsage)
+		return
+	}
+	hn := commandNetworkAPI(ce)
+	if hn == nil {
+		return
+	}
+
+	args := ce.Args
+	var amount float64
+	if parsed, err := strconv.ParseFloat(args[len(args)-1], 64); err == nil {
+		if math.IsNaN(parsed) || math.IsInf(parsed, 0) || parsed <= 0 {
+			ce.Reply("❌ The price must be a positive number")
+			return
+		} else if parsed > maxNightlyPrice {
+			ce.Reply("❌ The price can't be more than %d", maxNightlyPrice)
+			return
+		}
+		amount = parsed
+		args = args[:len(args)-1]
+	}
+	args, from, to, err := parseDateRange(args)
+	if err != nil {
+		// Not using format arguments, as $cmdprefix is only replaced in the format string
+		ce.Reply("❌ " + err.Error() + "\n\n" + priceUsage)
+		return
+	} else if len(args) == 0 {
+		ce.Reply(priceUsage)
+		return
+	}
+	property, err := hn.findProperty(ce.Ctx, strings.Join(args, " "))
+	if err != nil {
+		ce.Reply("❌ %v", err)
+		return
+	} else if len(property.Channels) == 0 {
+		ce.Reply("❌ **%s** isn't listed on any channel", property.Title)
+		return
+	}
+
+	calendars, err := hn.client.GetListingCalendars(ce.Ctx, property.Channels, from, to)
+	if err != nil {
+		ce.Reply("❌ Failed to get listing prices: %v", err)
+		return
+	}
+	summary := formatListingPrices(property, calendars, from, to)
+	if amount == 0 {
+		ce.Reply(summary)
+		return
+	}
+
+	change := &priceChange{hn: hn, property: property, from: from, to: to, amount: amount}
+	commands.StoreCommandState(ce.User, &commands.CommandState{
+		Next:   commands.MinimalCommandHandlerFunc(change.confirm),
+		Action: "Change listing prices",
+		Meta:   change,
+	})
+	ce.Reply(summary + "\n\n" + fmt.Sprintf(
+		"Change the nightly rate on all %d channels to **%s** from %s to %s? Reply `yes` to apply or anything else to cancel.",
+		len(property.Channels), strconv.FormatFloat(amount, 'f', 2, 64), from.Format(time.DateOnly), to.Format(time.DateOnly),
+	))
+}
+
+// priceChange is a price change waiting for confirmation from the user.
+type priceChange struct {
+	hn       *HostexNetworkAPI
+	property *hostexapi.Property
+	from     time.Time
+	to       time.Time
+	amount   float64
+}
+
+func (pc *priceChange) confirm(ce *commands.Event) {
+	commands.StoreCommandState(ce.User, nil)
+	switch strings.ToLower(strings.TrimSpace(ce.RawArgs)) {
+	case "yes", "y", "confirm":
+	default:
+		ce.Reply("Price change cancelled.")
+		return
+	}
+
+	price := hostexapi.ListingPrice{
+		StartDate: pc.from.Format(time.DateOnly),
+		EndDate:   pc.to.Format(time.DateOnly),
+		Price:     pc.amount,
+	}
+	var updated []string
+	var failed []string
+	for _, listing := range pc.property.Channels {
+		if err := pc.hn.client.UpdateListingPrices(ce.Ctx, listing, []hostexapi.ListingPrice{price}); err != nil {
+			pc.hn.br.Log.Error().Err(err).
+				Int("property_id", pc.property.ID).
+				Str("channel_type", listing.ChannelType).
+				Str("listing_id", listing.ListingID).
+				Msg("Failed to update listing price")
+			failed = append(failed, fmt.Sprintf("%s (%v)", listing.ChannelType, err))
+		} else {
+			updated = append(updated, listing.ChannelType)
+		}
+	}
+	pc.hn.br.Log.Info().
+		Int("property_id", pc.property.ID).
+		Str("from", price.StartDate).
+		Str("to", price.EndDate).
+		Float64("price", pc.amount).
+		Strs("updated_channels", updated).
+		Int("failed_channels", len(failed)).
+		Msg("Changed listing prices from Matrix")
+
+	result := fmt.Sprintf("💲 Nightly rate of **%s** from %s to %s set to **%s** on %s",
+		pc.property.Title, price.StartDate, price.EndDate, strconv.FormatFloat(pc.amount, 'f', 2, 64), strings.Join(updated, ", "))
+	if len(updated) == 0 {
+		result = fmt.Sprintf("❌ Failed to change the nightly rate of **%s**", pc.property.Title)
+	}
+	if len(failed) > 0 {
+		result += "\n\nFailed channels: " + strings.Join(failed, ", ")
+	}
+	ce.Reply(result)
+	pc.hn.logToManagementRoom(ce.Ctx, ce.User, ce.OrigRoomID, result)
+}
+
+// formatListingPrices renders the nightly rates of a property's listings as markdown.
+func formatListingPrices(property *hostexapi.Property, calendars []hostexapi.ListingCalendar, from, to time.Time) string {
+	currencies := make(map[string]string, len(property.Channels))
+	for _, channel := range property.Channels {
+		currencies[channel.ListingID] = channel.Currency
+	}
+
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(&buf, "💲 **%s** nightly rates from %s to %s\n\n", property.Title, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	for _, calendar := range calendars {
+		_, _ = fmt.Fprintf(&buf, "* **%s**: ", calendar.ChannelType)
+		if len(calendar.Calendar) == 0 {
+			buf.WriteString("no prices\n")
+			continue
+		}
+		minPrice, maxPrice, total := calendar.Calendar[0].Price, calendar.Calendar[0].Price, 0.0
+		for _, day := range calendar.Calendar {
+			minPrice = min(minPrice, day.Price)
+			maxPrice = max(maxPrice, day.Price)
+			total += day.Price
+		}
+		currency := currencies[calendar.ListingID]
+		if minPrice == maxPrice {
+			_, _ = fmt.Fprintf(&buf, "%.2f %s\n", minPrice, currency)
+		} else {
+			_, _ = fmt.Fprintf(&buf, "%.2f - %.2f %s (average %.2f)\n", minPrice, maxPrice, currency, total/float64(len(calendar.Calendar)))
+		}
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// logToManagementRoom records a change made with a command in the user's management room,
+// unless the command was already sent there.
+func (hn *HostexNetworkAPI) logToManagementRoom(ctx context.Context, user *bridgev2.User, commandRoom id.RoomID, message string) {
+	managementRoom, err := user.GetManagementRoom(ctx)
+	if err != nil {
+		hn.br.Log.Error().Err(err).Msg("Failed to get management room")
+		return
+	} else if managementRoom == commandRoom {
+		return
+	}
+	content := format.RenderMarkdown(message, true, false)
+	content.MsgType = event.MsgNotice
+	_, err = hn.br.Bot.SendMessage(ctx, managementRoom, event.EventMessage, &event.Content{Parsed: &content}, nil)
+	if err != nil {
+		hn.br.Log.Error().Err(err).Msg("Failed to send log message to management room")
+	}
+}
@@ -0,0 +1,112 @@
+package connector
+
+import (
+	"context"
+	"hostex-matrix-bridge/pkg/hostexapi"
+	"strings"
+	"unicode/utf8"
+
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/database"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+)
+
+const (
+	quotePrefix = "> "
+	// Number of recent portal messages searched for the target of a quoted reply
+	quoteSearchDepth = 100
+)
+
+// formatReplyFallback prefixes the text of a Matrix reply with a quote of the message it replies to,
+// since Hostex has no native replies.
+func (hn *HostexNetworkAPI) formatReplyFallback(ctx context.Context, msg *bridgev2.MatrixMessage, text string) string {
+	replyConfig := &hn.connector.Config.Replies
+	if msg.ReplyTo == nil || replyConfig.GetFallback() == ReplyFallbackNone {
+		return text
+	}
+	quoted := hn.getMessageText(ctx, msg.Portal, msg.ReplyTo)
+	if quoted == "" {
+		return text
+	}
+	quote := formatQuote(quoted, replyConfig.GetMaxQuoteLength())
+	if text == "" {
+		return quote
+	}
+	return quote + "\n\n" + text
+}
+
+// getMessageText returns the text of a bridged message, from the database metadata if it was
+// stored, or from the conversation history otherwise.
+func (hn *HostexNetworkAPI) getMessageText(ctx context.Context, portal *bridgev2.Portal, msg *database.Message) string {
+	if meta, ok := msg.Metadata.(*HostexMessageMetadata); ok && meta.Text != "" {
+		return meta.Text
+	} else if hostexapi.IsPendingMessageID(string(msg.ID)) {
+		return ""
+	}
+	details, err := hn.client.GetConversationDetails(ctx, string(portal.ID))
+	if err != nil {
+		hn.br.Log.Warn().Err(err).Str("message_id", string(msg.ID)).Msg("Failed to fetch replied-to message")
+		return ""
+	}
+	for _, hostexMsg := range details.Messages {
+		if hostexMsg.ID == string(msg.ID) {
+			return hostexMsg.Content
+		}
+	}
+	return ""
+}
+
+// formatQuote renders text as a "> " quote, truncated to maxLength characters.
+func formatQuote(text string, maxLength int) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) > maxLength {
+		text = strings.TrimSpace(string([]rune(text)[:maxLength])) + "…"
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = quotePrefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitQuote splits a message that starts with a "> " quote into the quoted text and the rest.
+func splitQuote(text string) (quote, rest string, ok bool) {
+	lines := strings.Split(text, "\n")
+	var quoteLines []string
+	for len(lines) > 0 && strings.HasPrefix(lines[0], ">") {
+		quoteLines = append(quoteLines, strings.TrimPrefix(strings.TrimPrefix(lines[0], ">"), " "))
+		lines = lines[1:]
+	}
+	rest = strings.TrimSpace(strings.Join(lines, "\n"))
+	if len(quoteLines) == 0 || rest == "" {
+		return "", text, false
+	}
+	return strings.Join(quoteLines, "\n"), rest, true
+}
+
+// findQuotedReply checks whether a message from Hostex starts with a quote of an earlier
+// message in the portal. If the quoted message is found, it's returned as the reply target
+// along with the text without the quote. Otherwise the text is returned unchanged.
+func (hn *HostexNetworkAPI) findQuotedReply(ctx context.Context, portal *bridgev2.Portal, text string) (*networkid.MessageOptionalPartID, string) {
+	quote, rest, ok := splitQuote(text)
+	if !ok {
+		return nil, text
+	}
+	quote = strings.TrimSpace(strings.TrimSuffix(quote, "…"))
+	if quote == "" {
+		return nil, text
+	}
+
+	recent, err := hn.br.DB.Message.GetLastNInPortal(ctx, portal.PortalKey, quoteSearchDepth)
+	if err != nil {
+		hn.br.Log.Warn().Err(err).Str("portal_id", string(portal.ID)).Msg("Failed to get recent messages to resolve quoted reply")
+		return nil, text
+	}
+	for _, msg := range recent {
+		meta, ok := msg.Metadata.(*HostexMessageMetadata)
+		if ok && meta.Text != "" && strings.HasPrefix(strings.TrimSpace(meta.Text), quote) {
+			return &networkid.MessageOptionalPartID{MessageID: msg.ID}, rest
+		}
+	}
+	return nil, text
+}
@@ -0,0 +1,255 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/commands"
+	"maunium.net/go/mautrix/bridgev2/database"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+const (
+	reviewPollInterval = time.Hour
+	// Reviews can be written for a few weeks after checkout
+	reviewLookback = 30 * 24 * time.Hour
+
+	// Reviews of conversations without a room aren't looked at again for this long
+	reviewWithoutRoomTTL = 6 * time.Hour
+
+	reviewMessageIDPrefix      = "review-"
+	reviewReplyMessageIDPrefix = "review-reply-"
+)
+
+func reviewMessageID(reservationCode string) networkid.MessageID {
+	return networkid.MessageID(reviewMessageIDPrefix + reservationCode)
+}
+
+func reviewReplyMessageID(reservationCode string) networkid.MessageID {
+	return networkid.MessageID(reviewReplyMessageIDPrefix + reservationCode)
+}
+
+// parseReviewMessageID returns the reservation code of a bridged guest review message.
+func parseReviewMessageID(messageID networkid.MessageID) (string, bool) {
+	if strings.HasPrefix(string(messageID), reviewReplyMessageIDPrefix) {
+		return "", false
+	}
+	return strings.CutPrefix(string(messageID), reviewMessageIDPrefix)
+}
+
+// syncReviews bridges reviews of recent stays that haven't been bridged yet.
+func (hn *HostexNetworkAPI) syncReviews(ctx context.Context) {
+	opts := hostexapi.ReviewListOptions{
+		ListOptions:  hostexapi.ListOptions{Limit: hostexapi.MaxPageSize},
+		CheckOutFrom: time.Now().Add(-reviewLookback),
+	}
+	for review, err := range hn.client.IterateReviews(ctx, opts) {
+		if err != nil {
+			hn.br.Log.Error().Err(err).Msg("Failed to fetch reviews")
+			return
+		}
+		if err := hn.bridgeReview(ctx, &review, ""); err != nil {
+			hn.br.Log.Error().Err(err).Str("reservation_code", review.ReservationCode).Msg("Failed to bridge review")
+		}
+	}
+}
+
+// bridgeReviewByCode fetches and bridges the reviews of a reservation, e.g. after a review_posted webhook.
+func (hn *HostexNetworkAPI) bridgeReviewByCode(ctx context.Context, reservationCode, conversationID string) error {
+	review, err := hn.client.GetReview(ctx, reservationCode)
+	if errors.Is(err, hostexapi.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get review: %w", err)
+	}
+	// Something changed, so look at the review again even if it was skipped recently.
+	// Messages that were already bridged are found in the database.
+	hn.state.forgetReview(reservationCode)
+	return hn.bridgeReview(ctx, review, conversationID)
+}
+
+// bridgeReview posts the guest review and the host reply of a reservation into the
+// conversation portal. Reviews are only bridged to conversations that already have a room,
+// and each is only sent once, as the message IDs are derived from the reservation code.
+func (hn *HostexNetworkAPI) bridgeReview(ctx context.Context, review *hostexapi.Review, conversationID string) error {
+	hasGuestReview := review.GuestReview != nil && review.GuestReview.Content != ""
+	hasHostReply := review.HostReply != nil && review.HostReply.Content != ""
+	if !hasGuestReview && !hasHostReply {
+		return nil
+	}
+	if hn.state.reviewBridged(review.ReservationCode, hasHostReply) || hn.state.reviewWithoutRoom(review.ReservationCode) {
+		return nil
+	} else if hn.reviewInDatabase(ctx, review.ReservationCode, hasGuestReview, hasHostReply) {
+		// Bridged before a restart
+		hn.state.markReviewBridged(review.ReservationCode, hasHostReply)
+		return nil
+	}
+
+	if conversationID == "" {
+		conversationID = hn.state.reviewConversation(review.ReservationCode)
+	}
+	if conversationID == "" {
+		reservation, err := hn.client.GetReservation(ctx, review.ReservationCode)
+		if errors.Is(err, hostexapi.ErrNotFound) {
+			hn.state.markReviewWithoutRoom(review.ReservationCode)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get reservation: %w", err)
+		}
+		conversationID = reservation.ConversationID
+	}
+	hn.state.setReviewConversation(review.ReservationCode, conversationID)
+	portalKey := networkid.PortalKey{
+		ID:       networkid.PortalID(conversationID),
+		Receiver: hn.login.ID,
+	}
+	portal, err := hn.br.GetExistingPortalByKey(ctx, portalKey)
+	if err != nil {
+		return fmt.Errorf("failed to get portal: %w", err)
+	} else if portal == nil || portal.MXID == "" {
+		hn.br.Log.Debug().
+			Str("reservation_code", review.ReservationCode).
+			Str("conversation_id", conversationID).
+			Msg("Not bridging review, conversation has no room")
+		hn.state.markReviewWithoutRoom(review.ReservationCode)
+		return nil
+	}
+
+	if hasGuestReview {
+		hn.queueReviewEvent(portalKey, reviewMessageID(review.ReservationCode), networkid.UserID("guest_"+conversationID), false, review.GuestReview, formatGuestReview(review))
+	}
+	if hasHostReply {
+		hn.queueReviewEvent(portalKey, reviewReplyMessageID(review.ReservationCode), networkid.UserID("host_"+string(hn.login.ID)), true, review.HostReply, "💬 **Response to review:**\n\n"+review.HostReply.Content)
+	}
+	hn.state.markReviewBridged(review.ReservationCode, hasHostReply)
+	return nil
+}
+
+// reviewInDatabase checks whether the messages of a review already exist in the bridge database.
+func (hn *HostexNetworkAPI) reviewInDatabase(ctx context.Context, reservationCode string, hasGuestReview, hasHostReply bool) bool {
+	for _, check := range []struct {
+		needed    bool
+		messageID networkid.MessageID
+	}{
+		{hasGuestReview, reviewMessageID(reservationCode)},
+		{hasHostReply, reviewReplyMessageID(reservationCode)},
+	} {
+		if !check.needed {
+			continue
+		}
+		msg, err := hn.br.DB.Message.GetFirstPartByID(ctx, hn.login.ID, check.messageID)
+		if err != nil {
+			hn.br.Log.Warn().Err(err).Str("reservation_code", reservationCode).Msg("Failed to check for bridged review")
+			return false
+		} else if msg == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// queueReviewEvent queues a review as a message. Messages that were already bridged are
+// ignored by the bridge, as their IDs already exist in the database.
+func (hn *HostexNetworkAPI) queueReviewEvent(portalKey networkid.PortalKey, messageID networkid.MessageID, sender networkid.UserID, isFromMe bool, content *hostexapi.ReviewContent, text string) {
+	timestamp := content.CreatedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	var senderLogin networkid.UserLoginID
+	if isFromMe {
+		senderLogin = hn.login.ID
+	}
+	//nolint:staticcheck // Using deprecated API until new simplevent API is properly documented
+	hn.br.QueueRemoteEvent(hn.login, &bridgev2.SimpleRemoteEvent[string]{
+		Type:      bridgev2.RemoteEventMessage,
+		PortalKey: portalKey,
+		ID:        messageID,
+		Timestamp: timestamp,
+		LogContext: func(c zerolog.Context) zerolog.Context {
+			return c.Str("message_id", string(messageID))
+		},
+		Sender: bridgev2.EventSender{
+			IsFromMe:    isFromMe,
+			SenderLogin: senderLogin,
+			Sender:      sender,
+		},
+		Data: text,
+		ConvertMessageFunc: func(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, data string) (*bridgev2.ConvertedMessage, error) {
+			content := format.RenderMarkdown(data, true, false)
+			content.MsgType = event.MsgNotice
+			return &bridgev2.ConvertedMessage{
+				Parts: []*bridgev2.ConvertedMessagePart{{
+					Type:    event.EventMessage,
+					Content: &content,
+				}},
+			}, nil
+		},
+	})
+}
+
+func formatGuestReview(review *hostexapi.Review) string {
+	var buf strings.Builder
+	buf.WriteString("⭐ **Guest review**")
+	if review.GuestReview.Score > 0 {
+		_, _ = fmt.Fprintf(&buf, " (%s/5)", strings.TrimSuffix(fmt.Sprintf("%.1f", review.GuestReview.Score), ".0"))
+	}
+	_, _ = fmt.Fprintf(&buf, " for the stay from %s to %s\n\n", review.CheckInDate, review.CheckOutDate)
+	for _, line := range strings.Split(review.GuestReview.Content, "\n") {
+		buf.WriteString("> " + line + "\n")
+	}
+	buf.WriteString("\nReply to this message to respond to the review publicly.")
+	return buf.String()
+}
+
+// handleMatrixReviewReply posts a Matrix reply to a bridged guest review as the host's public response.
+func (hn *HostexNetworkAPI) handleMatrixReviewReply(ctx context.Context, msg *bridgev2.MatrixMessage, reservationCode string) (*bridgev2.MatrixMessageResponse, error) {
+	switch msg.Content.MsgType {
+	case event.MsgText, event.MsgNotice, event.MsgEmote:
+	default:
+		return nil, bridgev2.ErrUnsupportedMessageType
+	}
+	if err := hn.client.ReplyToReview(ctx, reservationCode, msg.Content.Body); err != nil {
+		return nil, fmt.Errorf("failed to reply to review: %w", err)
+	}
+	hn.state.markReviewBridged(reservationCode, true)
+	hn.br.Log.Info().Str("reservation_code", reservationCode).Msg("Replied to review from Matrix")
+	return &bridgev2.MatrixMessageResponse{
+		DB: &database.Message{
+			ID:        reviewReplyMessageID(reservationCode),
+			MXID:      msg.Event.ID,
+			Room:      msg.Portal.PortalKey,
+			SenderID:  networkid.UserID("host_" + string(hn.login.ID)),
+			Timestamp: time.Now(),
+		},
+	}, nil
+}
+
+// handleReviewReplyCommand handles the review-reply command.
+func (hc *HostexConnector) handleReviewReplyCommand(ce *commands.Event) {
+	if len(ce.Args) < 2 {
+		ce.Reply("**Usage:** `$cmdprefix review-reply <reservation code> <response>`")
+		return
+	}
+	hn := commandNetworkAPI(ce)
+	if hn == nil {
+		return
+	}
+	reservationCode := ce.Args[0]
+	response := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(ce.RawArgs), reservationCode))
+	if err := hn.client.ReplyToReview(ce.Ctx, reservationCode, response); err != nil {
+		ce.Reply("❌ Failed to reply to review: %v", err)
+		return
+	}
+	ce.Reply("✅ Posted response to the review of reservation `%s`", reservationCode)
+	// Bridge the response into the conversation room on the next review sync
+	hn.state.forgetReview(reservationCode)
+}
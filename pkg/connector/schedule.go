@@ -0,0 +1,164 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+	// Property timezones are needed for quiet hours and the runtime image has no tzdata
+	_ "time/tzdata"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+// How often property timezones are refreshed for quiet hours.
+const propertyTimezoneRefreshInterval = 6 * time.Hour
+
+// pollSchedule keeps what the poll loop needs to decide when to poll next.
+type pollSchedule struct {
+	lock sync.Mutex
+	// Newest last_message_at of the conversations in scope
+	lastActivity time.Time
+	// Whether a guest of a conversation in scope is currently checked in
+	checkedIn bool
+	// Current rate limit backoff, 0 if the last poll wasn't rate limited
+	backoff time.Duration
+	// Timezones of the properties on the account
+	timezones        []*time.Location
+	timezonesFetched time.Time
+}
+
+// observeConversations records the activity of the conversations checked in a poll cycle.
+func (ps *pollSchedule) observeConversations(conversations []hostexapi.Conversation, now time.Time) {
+	today := now.Format(time.DateOnly)
+	var lastActivity time.Time
+	checkedIn := false
+	for _, conv := range conversations {
+		if conv.LastMessageAt.After(lastActivity) {
+			lastActivity = conv.LastMessageAt
+		}
+		// Dates are YYYY-MM-DD, so plain string comparison orders them correctly
+		if conv.CheckInDate != "" && conv.CheckInDate <= today && today <= conv.CheckOutDate {
+			checkedIn = true
+		}
+	}
+	ps.lock.Lock()
+	ps.lastActivity = lastActivity
+	ps.checkedIn = checkedIn
+	ps.lock.Unlock()
+}
+
+// refreshPropertyTimezones reloads the property timezones if they're stale. Failures are only
+// logged, quiet hours keep using the previous timezones or the configured fallback.
+func (hn *HostexNetworkAPI) refreshPropertyTimezones(ctx context.Context) {
+	hn.schedule.lock.Lock()
+	fresh := time.Since(hn.schedule.timezonesFetched) < propertyTimezoneRefreshInterval
+	hn.schedule.lock.Unlock()
+	if fresh {
+		return
+	}
+
+	properties, err := hn.client.GetProperties(ctx)
+	if err != nil {
+		hn.br.Log.Warn().Err(err).Msg("Failed to fetch property timezones for poll scheduling")
+		return
+	}
+	seen := make(map[string]bool)
+	var timezones []*time.Location
+	for _, property := range properties {
+		if property.Timezone == "" || seen[property.Timezone] {
+			continue
+		}
+		seen[property.Timezone] = true
+		loc, err := time.LoadLocation(property.Timezone)
+		if err != nil {
+			hn.br.Log.Debug().Err(err).Int("property_id", property.ID).Str("timezone", property.Timezone).Msg("Unknown property timezone")
+			continue
+		}
+		timezones = append(timezones, loc)
+	}
+	hn.schedule.lock.Lock()
+	hn.schedule.timezones = timezones
+	hn.schedule.timezonesFetched = time.Now()
+	hn.schedule.lock.Unlock()
+}
+
+// inQuietHours checks whether it's currently quiet hours at every property.
+func (hn *HostexNetworkAPI) inQuietHours(now time.Time) bool {
+	pollConfig := &hn.connector.Config.Poll
+	start, end, ok := pollConfig.GetQuietHours()
+	if !ok {
+		return false
+	}
+	hn.schedule.lock.Lock()
+	timezones := hn.schedule.timezones
+	hn.schedule.lock.Unlock()
+	if len(timezones) == 0 {
+		timezones = []*time.Location{pollConfig.GetTimezone()}
+	}
+	for _, loc := range timezones {
+		local := now.In(loc)
+		minute := local.Hour()*60 + local.Minute()
+		var quiet bool
+		if start < end {
+			quiet = minute >= start && minute < end
+		} else {
+			quiet = minute >= start || minute < end
+		}
+		if !quiet {
+			return false
+		}
+	}
+	return true
+}
+
+// nextPollDelay decides how long to wait before the next poll based on the result of the
+// previous one. Rate limits double the interval up to the configured maximum, recent messages
+// and checked in guests speed polling up unless webhooks are delivered, and quiet hours slow
+// it down. Each interval is randomized by the configured jitter.
+func (hn *HostexNetworkAPI) nextPollDelay(ctx context.Context, pollErr error, webhooks bool) time.Duration {
+	pollConfig := &hn.connector.Config.Poll
+	now := time.Now()
+	base := pollConfig.GetInterval()
+	if webhooks {
+		base = pollConfig.GetWebhookInterval()
+	}
+
+	hn.schedule.lock.Lock()
+	if errors.Is(pollErr, hostexapi.ErrRateLimited) {
+		hn.schedule.backoff = min(max(2*hn.schedule.backoff, 2*base), pollConfig.GetMaxBackoff())
+	} else if pollErr == nil {
+		hn.schedule.backoff = 0
+	}
+	backoff := hn.schedule.backoff
+	recentlyActive := now.Sub(hn.schedule.lastActivity) < pollConfig.GetActiveWindow()
+	checkedIn := hn.schedule.checkedIn
+	hn.schedule.lock.Unlock()
+
+	if backoff == 0 {
+		hn.refreshPropertyTimezones(ctx)
+	}
+
+	delay := base
+	reason := "base"
+	switch {
+	case backoff > 0:
+		delay, reason = max(base, backoff), "rate_limited"
+	case recentlyActive && !webhooks:
+		delay, reason = pollConfig.GetActiveInterval(), "recent_activity"
+	case hn.inQuietHours(now):
+		delay, reason = max(base, pollConfig.GetQuietInterval()), "quiet_hours"
+	case checkedIn && !webhooks:
+		delay, reason = pollConfig.GetActiveInterval(), "guest_checked_in"
+	}
+	if jitter := pollConfig.GetJitter(); jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + jitter*(2*rand.Float64()-1)))
+	}
+
+	hn.br.Log.Debug().
+		Str("reason", reason).
+		Stringer("delay", delay).
+		Msg("Scheduled next poll")
+	return delay
+}
@@ -0,0 +1,332 @@
+package connector
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+const (
+	// Maximum number of conversations kept in memory per login. Sync cursors of evicted
+	// conversations are reloaded from the database when they're needed again.
+	maxStateConversations = 5000
+	// Conversations that haven't been looked at for this long are evicted. Conversations in
+	// the sync scope are looked at on every poll, so only inactive ones expire.
+	conversationStateTTL = 24 * time.Hour
+	// Maximum number of bridged reviews remembered per login.
+	maxStateReviews = 5000
+	// Reviews are only synced within the lookback window, so there's no need to remember them longer.
+	reviewStateTTL = reviewLookback + 24*time.Hour
+)
+
+// lruCache is a map that evicts the least recently used entries once it's full or
+// they haven't been used for the TTL. It isn't safe for concurrent use on its own.
+type lruCache[V any] struct {
+	items   map[string]*list.Element
+	order   *list.List // front is the most recently used entry
+	maxSize int
+	ttl     time.Duration
+}
+
+type lruEntry[V any] struct {
+	key      string
+	value    V
+	lastUsed time.Time
+}
+
+func newLRUCache[V any](maxSize int, ttl time.Duration) *lruCache[V] {
+	return &lruCache[V]{
+		items:   make(map[string]*list.Element),
+		order:   list.New(),
+		maxSize: maxSize,
+		ttl:     ttl,
+	}
+}
+
+// get returns the value of a key and marks it as used.
+func (c *lruCache[V]) get(key string, now time.Time) (value V, ok bool) {
+	c.evict(now)
+	elem, ok := c.items[key]
+	if !ok {
+		return value, false
+	}
+	entry := elem.Value.(*lruEntry[V])
+	entry.lastUsed = now
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// peek returns the value of a key without marking it as used, so it still expires
+// the TTL after it was last put.
+func (c *lruCache[V]) peek(key string, now time.Time) (value V, ok bool) {
+	c.evict(now)
+	elem, ok := c.items[key]
+	if !ok {
+		return value, false
+	}
+	return elem.Value.(*lruEntry[V]).value, true
+}
+
+func (c *lruCache[V]) put(key string, value V, now time.Time) {
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry[V])
+		entry.value = value
+		entry.lastUsed = now
+		c.order.MoveToFront(elem)
+	} else {
+		c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, lastUsed: now})
+	}
+	c.evict(now)
+}
+
+func (c *lruCache[V]) remove(key string) {
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// evict drops expired entries and the least recently used ones above the size limit.
+// Entries are ordered by last use, so both are found at the back of the list.
+func (c *lruCache[V]) evict(now time.Time) {
+	for elem := c.order.Back(); elem != nil; elem = c.order.Back() {
+		entry := elem.Value.(*lruEntry[V])
+		if c.order.Len() <= c.maxSize && now.Sub(entry.lastUsed) < c.ttl {
+			return
+		}
+		c.order.Remove(elem)
+		delete(c.items, entry.key)
+	}
+}
+
+func (c *lruCache[V]) each(fn func(key string, value V)) {
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*lruEntry[V])
+		fn(entry.key, entry.value)
+	}
+}
+
+// conversationState is what's remembered about a conversation between polls.
+type conversationState struct {
+	guestName string
+	// last_message_at from the conversations endpoint when the conversation was last synced
+	lastMessageAt time.Time
+	// Timestamp of the newest message that was queued for bridging
+	lastProcessedAt time.Time
+	// Messages sent from Matrix waiting for their echo in the Hostex history
+	pending []*pendingMessage
+	// Whether the sync cursors were loaded from the database. The state can exist without
+	// them, e.g. when a message is sent to a conversation that was evicted.
+	cursorsLoaded bool
+}
+
+// conversationLock serializes processing of a conversation. It's only kept while in use.
+type conversationLock struct {
+	lock sync.Mutex
+	refs int
+}
+
+// stateStore holds the in-memory state of a login. It's safe for concurrent use and
+// bounded in size, so long-running bridges don't accumulate state for old conversations.
+type stateStore struct {
+	lock          sync.Mutex
+	conversations *lruCache[*conversationState]
+	// Conversation ID -> lock held while processing it, removed once nobody holds or waits for it
+	conversationLocks map[string]*conversationLock
+	// Reservation code -> whether the host reply to the review was bridged too
+	reviews *lruCache[bool]
+	// Reservation code -> conversation ID, so reservations are only looked up once per review
+	reviewConversations *lruCache[string]
+	// Reservation codes of reviews whose conversation has no room, or no conversation at all
+	reviewsWithoutRoom *lruCache[struct{}]
+}
+
+func newStateStore() *stateStore {
+	return &stateStore{
+		conversations:     newLRUCache[*conversationState](maxStateConversations, conversationStateTTL),
+		conversationLocks: make(map[string]*conversationLock),
+		reviews:           newLRUCache[bool](maxStateReviews, reviewStateTTL),
+
+		reviewConversations: newLRUCache[string](maxStateReviews, reviewStateTTL),
+		reviewsWithoutRoom:  newLRUCache[struct{}](maxStateReviews, reviewWithoutRoomTTL),
+	}
+}
+
+// conversationLocked returns the state of a conversation, creating it if create is true.
+func (ss *stateStore) conversationLocked(conversationID string, create bool) *conversationState {
+	now := time.Now()
+	state, ok := ss.conversations.get(conversationID, now)
+	if !ok && create {
+		state = &conversationState{}
+		ss.conversations.put(conversationID, state, now)
+	}
+	return state
+}
+
+// lockConversation serializes processing of a single conversation and returns the unlock function.
+func (ss *stateStore) lockConversation(conversationID string) func() {
+	ss.lock.Lock()
+	convLock, ok := ss.conversationLocks[conversationID]
+	if !ok {
+		convLock = &conversationLock{}
+		ss.conversationLocks[conversationID] = convLock
+	}
+	convLock.refs++
+	ss.lock.Unlock()
+
+	convLock.lock.Lock()
+	return func() {
+		convLock.lock.Unlock()
+		ss.lock.Lock()
+		convLock.refs--
+		if convLock.refs == 0 {
+			delete(ss.conversationLocks, conversationID)
+		}
+		ss.lock.Unlock()
+	}
+}
+
+// cursorsLoaded checks whether the sync cursors of a conversation are in memory,
+// i.e. whether they can be used without looking at the database.
+func (ss *stateStore) cursorsLoaded(conversationID string) bool {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	state := ss.conversationLocked(conversationID, false)
+	return state != nil && state.cursorsLoaded
+}
+
+func (ss *stateStore) guestName(conversationID string) string {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	if state := ss.conversationLocked(conversationID, false); state != nil {
+		return state.guestName
+	}
+	return ""
+}
+
+func (ss *stateStore) setGuestName(conversationID, name string) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.conversationLocked(conversationID, true).guestName = name
+}
+
+// cursors returns the sync cursors of a conversation. Zero times mean the conversation hasn't been synced.
+func (ss *stateStore) cursors(conversationID string) (lastMessageAt, lastProcessedAt time.Time) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	if state := ss.conversationLocked(conversationID, false); state != nil {
+		return state.lastMessageAt, state.lastProcessedAt
+	}
+	return time.Time{}, time.Time{}
+}
+
+// restoreCursors sets the sync cursors loaded from the database, unless the conversation was synced further in the meantime.
+// Zero times can be passed for conversations that have no cursors in the database.
+func (ss *stateStore) restoreCursors(conversationID string, lastMessageAt, lastProcessedAt time.Time) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	state := ss.conversationLocked(conversationID, true)
+	state.cursorsLoaded = true
+	if lastMessageAt.After(state.lastMessageAt) {
+		state.lastMessageAt = lastMessageAt
+	}
+	if lastProcessedAt.After(state.lastProcessedAt) {
+		state.lastProcessedAt = lastProcessedAt
+	}
+}
+
+func (ss *stateStore) setLastMessageAt(conversationID string, lastMessageAt time.Time) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.conversationLocked(conversationID, true).lastMessageAt = lastMessageAt
+}
+
+func (ss *stateStore) setLastProcessedAt(conversationID string, lastProcessedAt time.Time) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.conversationLocked(conversationID, true).lastProcessedAt = lastProcessedAt
+}
+
+// clearLastMessageAt forgets last_message_at of every conversation, so the next poll checks all of them.
+func (ss *stateStore) clearLastMessageAt() {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.conversations.each(func(_ string, state *conversationState) {
+		state.lastMessageAt = time.Time{}
+	})
+}
+
+func (ss *stateStore) addPendingMessage(conversationID string, msg *pendingMessage) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	state := ss.conversationLocked(conversationID, true)
+	state.pending = append(prunePendingMessages(state.pending, time.Now()), msg)
+}
+
+// matchPendingMessage finds and removes the pending message that a message from the Hostex history is the echo of.
+func (ss *stateStore) matchPendingMessage(conversationID string, msg *hostexapi.Message) *pendingMessage {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	state := ss.conversationLocked(conversationID, false)
+	if state == nil {
+		return nil
+	}
+	state.pending = prunePendingMessages(state.pending, time.Now())
+	i := findPendingMessage(state.pending, msg)
+	if i < 0 {
+		return nil
+	}
+	match := state.pending[i]
+	state.pending = append(state.pending[:i:i], state.pending[i+1:]...)
+	return match
+}
+
+// reviewBridged checks whether everything in a review was already queued for bridging.
+func (ss *stateStore) reviewBridged(reservationCode string, hasHostReply bool) bool {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	repliedTo, ok := ss.reviews.get(reservationCode, time.Now())
+	return ok && (repliedTo || !hasHostReply)
+}
+
+func (ss *stateStore) markReviewBridged(reservationCode string, hasHostReply bool) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.reviews.put(reservationCode, hasHostReply, time.Now())
+}
+
+func (ss *stateStore) forgetReview(reservationCode string) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.reviews.remove(reservationCode)
+	ss.reviewsWithoutRoom.remove(reservationCode)
+}
+
+func (ss *stateStore) reviewConversation(reservationCode string) string {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	conversationID, _ := ss.reviewConversations.get(reservationCode, time.Now())
+	return conversationID
+}
+
+func (ss *stateStore) setReviewConversation(reservationCode, conversationID string) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.reviewConversations.put(reservationCode, conversationID, time.Now())
+}
+
+// reviewWithoutRoom checks whether a review recently couldn't be bridged because its conversation has no room.
+func (ss *stateStore) reviewWithoutRoom(reservationCode string) bool {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	_, ok := ss.reviewsWithoutRoom.peek(reservationCode, time.Now())
+	return ok
+}
+
+func (ss *stateStore) markReviewWithoutRoom(reservationCode string) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.reviewsWithoutRoom.put(reservationCode, struct{}{}, time.Now())
+}
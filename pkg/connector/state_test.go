@@ -0,0 +1,194 @@
+package connector
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"maunium.net/go/mautrix/bridgev2/networkid"
+
+	"hostex-matrix-bridge/pkg/hostexapi"
+)
+
+func TestLRUCacheSizeEviction(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := newLRUCache[int](3, time.Hour)
+	cache.put("a", 1, now)
+	cache.put("b", 2, now)
+	cache.put("c", 3, now)
+	// Using a makes b the least recently used entry
+	if _, ok := cache.get("a", now); !ok {
+		t.Fatal("a missing before eviction")
+	}
+	cache.put("d", 4, now)
+
+	if _, ok := cache.get("b", now); ok {
+		t.Error("least recently used entry wasn't evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := cache.get(key, now); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if cache.order.Len() != 3 || len(cache.items) != 3 {
+		t.Errorf("cache has %d/%d entries, want 3", cache.order.Len(), len(cache.items))
+	}
+}
+
+func TestLRUCacheTTLEviction(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := newLRUCache[int](10, time.Minute)
+	cache.put("old", 1, now)
+	cache.put("used", 2, now)
+	if _, ok := cache.get("used", now.Add(50*time.Second)); !ok {
+		t.Fatal("entry expired early")
+	}
+
+	later := now.Add(70 * time.Second)
+	if _, ok := cache.get("old", later); ok {
+		t.Error("expired entry was returned")
+	}
+	if _, ok := cache.get("used", later); !ok {
+		t.Error("recently used entry expired")
+	}
+	if _, ok := cache.peek("used", later.Add(50*time.Second)); !ok {
+		t.Error("entry expired before the TTL passed since it was last used")
+	}
+	if _, ok := cache.peek("used", later.Add(2*time.Minute)); ok {
+		t.Error("peek kept the entry alive")
+	}
+	if len(cache.items) != 0 {
+		t.Errorf("cache has %d entries, want 0", len(cache.items))
+	}
+}
+
+func TestStateStoreRestoreCursors(t *testing.T) {
+	ss := newStateStore()
+	synced := time.Unix(1700000000, 0)
+	ss.setLastMessageAt("conv", synced)
+	ss.setLastProcessedAt("conv", synced)
+	if ss.cursorsLoaded("conv") {
+		t.Fatal("cursors reported as loaded before restoring them")
+	}
+
+	ss.restoreCursors("conv", synced.Add(-time.Hour), synced.Add(-time.Hour))
+	lastMessageAt, lastProcessedAt := ss.cursors("conv")
+	if !lastMessageAt.Equal(synced) || !lastProcessedAt.Equal(synced) {
+		t.Errorf("older cursors from the database overwrote newer ones: %v, %v", lastMessageAt, lastProcessedAt)
+	}
+	if !ss.cursorsLoaded("conv") {
+		t.Error("cursors not reported as loaded after restoring them")
+	}
+
+	ss.restoreCursors("other", synced, time.Time{})
+	lastMessageAt, lastProcessedAt = ss.cursors("other")
+	if !lastMessageAt.Equal(synced) || !lastProcessedAt.IsZero() {
+		t.Errorf("got cursors %v, %v for a new conversation", lastMessageAt, lastProcessedAt)
+	}
+}
+
+func TestStateStorePendingMessageDoesNotMarkCursorsLoaded(t *testing.T) {
+	ss := newStateStore()
+	ss.addPendingMessage("conv", &pendingMessage{MessageID: "pending", SentAt: time.Now()})
+	if ss.cursorsLoaded("conv") {
+		t.Fatal("sending a message marked the sync cursors as loaded")
+	}
+}
+
+func TestStateStorePendingMessages(t *testing.T) {
+	ss := newStateStore()
+	now := time.Now()
+	ss.addPendingMessage("conv", &pendingMessage{MessageID: "expired", ContentHash: hashMessageContent("Hi"), SentAt: now.Add(-pendingMessageTTL - time.Minute)})
+	ss.addPendingMessage("conv", &pendingMessage{MessageID: "pending", ContentHash: hashMessageContent("Hi"), SentAt: now})
+
+	msg := &hostexapi.Message{ID: "real", SenderRole: "host", Content: "Hi", CreatedAt: now}
+	if ss.matchPendingMessage("other", msg) != nil {
+		t.Error("matched a message in a different conversation")
+	}
+	match := ss.matchPendingMessage("conv", msg)
+	if match == nil || match.MessageID != networkid.MessageID("pending") {
+		t.Fatalf("got %+v, want the unexpired pending message", match)
+	}
+	if ss.matchPendingMessage("conv", msg) != nil {
+		t.Error("pending message matched twice")
+	}
+}
+
+func TestStateStoreReviews(t *testing.T) {
+	ss := newStateStore()
+	if ss.reviewBridged("code", false) {
+		t.Fatal("unknown review reported as bridged")
+	}
+	ss.markReviewBridged("code", false)
+	if !ss.reviewBridged("code", false) {
+		t.Error("review not reported as bridged")
+	}
+	if ss.reviewBridged("code", true) {
+		t.Error("host reply reported as bridged before it was")
+	}
+	ss.markReviewWithoutRoom("code")
+	ss.forgetReview("code")
+	if ss.reviewBridged("code", false) || ss.reviewWithoutRoom("code") {
+		t.Error("forgotten review still remembered")
+	}
+}
+
+func TestStateStoreConversationLocks(t *testing.T) {
+	ss := newStateStore()
+	var wg sync.WaitGroup
+	counter := 0
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := ss.lockConversation("conv")
+			// Not atomic on purpose, the race detector catches missing serialization
+			counter++
+			unlock()
+		}()
+	}
+	wg.Wait()
+	if counter != 50 {
+		t.Errorf("counter is %d, want 50", counter)
+	}
+	if len(ss.conversationLocks) != 0 {
+		t.Errorf("%d conversation locks left after all were released", len(ss.conversationLocks))
+	}
+}
+
+func TestStateStoreConcurrentAccess(t *testing.T) {
+	ss := newStateStore()
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				conversationID := fmt.Sprintf("conv-%d", i%20)
+				now := time.Now()
+				switch (worker + i) % 8 {
+				case 0:
+					ss.setGuestName(conversationID, "Guest")
+				case 1:
+					_ = ss.guestName(conversationID)
+				case 2:
+					ss.setLastMessageAt(conversationID, now)
+				case 3:
+					ss.restoreCursors(conversationID, now, now)
+				case 4:
+					ss.addPendingMessage(conversationID, &pendingMessage{SentAt: now})
+				case 5:
+					ss.matchPendingMessage(conversationID, &hostexapi.Message{SenderRole: "host", CreatedAt: now})
+				case 6:
+					ss.clearLastMessageAt()
+				case 7:
+					unlock := ss.lockConversation(conversationID)
+					ss.markReviewBridged(conversationID, true)
+					unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
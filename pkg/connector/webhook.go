@@ -0,0 +1,395 @@
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hostex-matrix-bridge/pkg/hostexapi"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/random"
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+)
+
+// handleWebhook handles incoming webhooks from Hostex
+func (hc *HostexConnector) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	// The path isn't logged, as the URL is meant to be known only to Hostex
+	hc.br.Log.Info().Str("method", r.Method).Msg("Received webhook")
+
+	// Read the request body. The endpoint is public and the body is read before the
+	// signature is checked, so it's limited to avoid buffering arbitrarily large requests.
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		hc.webhookMetrics.Invalid.Add(1)
+		hc.br.Log.Warn().Int64("limit", maxBytesErr.Limit).Msg("Rejected oversized webhook body")
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		hc.br.Log.Error().Err(err).Msg("Failed to read webhook body")
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	webhookConfig := &hc.Config.Webhook
+	if err := verifyWebhookSignature(webhookConfig.Secret, r, body); err != nil {
+		hc.webhookMetrics.Unauthorized.Add(1)
+		hc.br.Log.Warn().Err(err).Str("remote_addr", r.RemoteAddr).Msg("Rejected unauthenticated webhook")
+		http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	evt, err := hostexapi.ParseWebhookEvent(body)
+	if err != nil {
+		hc.webhookMetrics.Invalid.Add(1)
+		hc.br.Log.Warn().Err(err).Msg("Failed to parse webhook payload")
+		http.Error(w, "Invalid webhook payload", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	tolerance := webhookConfig.GetTimestampTolerance()
+	if err := checkWebhookTimestamp(tolerance, r, evt.Timestamp, now); err != nil {
+		hc.webhookMetrics.Stale.Add(1)
+		hc.br.Log.Warn().Err(err).Str("webhook_event_id", evt.ID).Msg("Rejected stale webhook")
+		http.Error(w, "Webhook timestamp outside allowed window", http.StatusUnauthorized)
+		return
+	}
+
+	// Deliveries without an ID are deduplicated by their content
+	dedupeKey := evt.ID
+	if dedupeKey == "" {
+		hash := sha256.Sum256(body)
+		dedupeKey = "sha256:" + hex.EncodeToString(hash[:])
+	}
+	if hc.webhookDedupe.markSeen(dedupeKey, now) {
+		// Acknowledge duplicates so Hostex stops retrying, but don't process them again
+		hc.webhookMetrics.Duplicate.Add(1)
+		hc.br.Log.Warn().Str("webhook_event_id", evt.ID).Msg("Ignoring replayed webhook")
+		hc.writeWebhookResponse(w, "duplicate")
+		return
+	}
+	hc.webhookMetrics.Accepted.Add(1)
+
+	log := hc.br.Log.With().
+		Str("webhook_event_id", evt.ID).
+		Str("webhook_event_type", string(evt.Type)).
+		Logger()
+	log.Debug().Msg("Parsed webhook event")
+
+	// Hostex expects a quick answer, so the event is handled in the background. Events
+	// that fail to be handled are picked up by the next poll instead of a redelivery.
+	webhookID := r.PathValue("webhookID")
+	go func() {
+		if err := hc.dispatchWebhookEvent(log.WithContext(hc.br.BackgroundCtx), webhookID, evt); err != nil {
+			log.Err(err).Msg("Failed to dispatch webhook event")
+		}
+	}()
+
+	hc.writeWebhookResponse(w, "received")
+}
+
+func (hc *HostexConnector) writeWebhookResponse(w http.ResponseWriter, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"bridge": "mautrix-hostex",
+	}); err != nil {
+		hc.br.Log.Error().Err(err).Msg("Failed to encode webhook response")
+	}
+}
+
+// dispatchWebhookEvent routes a webhook event to the logins it concerns.
+// An error is returned if the event couldn't be handled for any of them.
+func (hc *HostexConnector) dispatchWebhookEvent(ctx context.Context, webhookID string, evt *hostexapi.WebhookEvent) error {
+	log := zerolog.Ctx(ctx)
+
+	var conversationID string
+	switch {
+	case evt.Message != nil:
+		conversationID = evt.Message.ConversationID
+	case evt.Reservation != nil:
+		conversationID = evt.Reservation.ConversationID
+	case evt.Review != nil:
+		conversationID = evt.Review.ConversationID
+	default:
+		log.Debug().Msg("Ignoring webhook event of unknown type")
+		return nil
+	}
+
+	logins, err := hc.getWebhookTargets(ctx, webhookID, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to find logins for webhook event: %w", err)
+	} else if len(logins) == 0 {
+		log.Warn().Msg("No Hostex logins found for webhook event")
+		return nil
+	}
+
+	var errs []error
+	for _, hn := range logins {
+		if !hn.webhookDelivered.Swap(true) {
+			log.Info().Str("login_id", string(hn.login.ID)).Msg("Webhook deliveries are arriving, slowing down polling")
+		}
+		if err := hn.handleWebhookEvent(ctx, evt, conversationID); err != nil {
+			log.Err(err).Str("login_id", string(hn.login.ID)).Msg("Failed to handle webhook event")
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// getWebhookTargets finds the logins a webhook event should be delivered to.
+// Webhooks registered per login carry the login's webhook ID in the path. Otherwise the
+// owner of the conversation's portal is used, falling back to every Hostex login.
+func (hc *HostexConnector) getWebhookTargets(ctx context.Context, webhookID string, conversationID string) ([]*HostexNetworkAPI, error) {
+	if webhookID != "" {
+		clients, err := hc.getAllHostexClients(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, hn := range clients {
+			if meta := hn.login.Metadata.(*HostexUserLoginMetadata); meta.WebhookID != "" && meta.WebhookID == webhookID {
+				return []*HostexNetworkAPI{hn}, nil
+			}
+		}
+		return nil, nil
+	}
+
+	if conversationID != "" {
+		portalKey, err := hc.br.FindPortalReceiver(ctx, networkid.PortalID(conversationID), "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to find portal receiver: %w", err)
+		}
+		if portalKey.Receiver != "" {
+			if hn, ok := hostexClientOf(hc.br.GetCachedUserLoginByID(portalKey.Receiver)); ok {
+				return []*HostexNetworkAPI{hn}, nil
+			}
+		}
+	}
+
+	return hc.getAllHostexClients(ctx)
+}
+
+// getAllHostexClients returns the network API of every loaded Hostex login.
+func (hc *HostexConnector) getAllHostexClients(ctx context.Context) ([]*HostexNetworkAPI, error) {
+	userIDs, err := hc.br.DB.UserLogin.GetAllUserIDsWithLogins(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users with logins: %w", err)
+	}
+	var clients []*HostexNetworkAPI
+	for _, userID := range userIDs {
+		user, err := hc.br.GetExistingUserByMXID(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user %s: %w", userID, err)
+		} else if user == nil {
+			continue
+		}
+		for _, login := range user.GetUserLogins() {
+			if hn, ok := hostexClientOf(login); ok {
+				clients = append(clients, hn)
+			}
+		}
+	}
+	return clients, nil
+}
+
+func hostexClientOf(login *bridgev2.UserLogin) (*HostexNetworkAPI, bool) {
+	if login == nil || login.Client == nil {
+		return nil, false
+	}
+	hn, ok := login.Client.(*HostexNetworkAPI)
+	return hn, ok
+}
+
+// handleWebhookEvent triggers a targeted fetch for the conversation or reservation a webhook event is about.
+func (hn *HostexNetworkAPI) handleWebhookEvent(ctx context.Context, evt *hostexapi.WebhookEvent, conversationID string) error {
+	if evt.Review != nil && evt.Review.ReservationCode != "" {
+		zerolog.Ctx(ctx).Info().
+			Str("reservation_code", evt.Review.ReservationCode).
+			Str("login_id", string(hn.login.ID)).
+			Msg("Bridging review from webhook event")
+		return hn.bridgeReviewByCode(ctx, evt.Review.ReservationCode, conversationID)
+	}
+	if conversationID == "" && evt.Reservation != nil && evt.Reservation.ReservationCode != "" {
+		var err error
+		conversationID, err = hn.findReservationConversation(ctx, evt.Reservation)
+		if err != nil {
+			return err
+		}
+	}
+	if conversationID == "" {
+		zerolog.Ctx(ctx).Debug().Msg("Webhook event doesn't reference a conversation, ignoring")
+		return nil
+	}
+
+	zerolog.Ctx(ctx).Info().
+		Str("conversation_id", conversationID).
+		Str("login_id", string(hn.login.ID)).
+		Msg("Refreshing conversation from webhook event")
+	return hn.refreshConversation(ctx, conversationID)
+}
+
+// findReservationConversation looks up the conversation of a reservation that was
+// delivered in a webhook without its conversation ID.
+func (hn *HostexNetworkAPI) findReservationConversation(ctx context.Context, evt *hostexapi.ReservationEvent) (string, error) {
+	reservation, err := hn.client.GetReservation(ctx, evt.ReservationCode)
+	if errors.Is(err, hostexapi.ErrNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to look up reservation: %w", err)
+	}
+	return reservation.ConversationID, nil
+}
+
+// webhookDeliveryGracePeriod is how long a webhook delivery may take to arrive after a message
+// was sent, before a poll finding the message counts it as missed.
+const webhookDeliveryGracePeriod = time.Minute
+
+// checkMissedWebhooks goes back to the regular poll interval if a poll found new messages that
+// no webhook announced, as Hostex may have stopped delivering to the bridge. The slower interval
+// is used again once the next delivery arrives.
+func (hn *HostexNetworkAPI) checkMissedWebhooks(missed int) {
+	if missed > 0 && hn.webhookDelivered.Swap(false) {
+		hn.br.Log.Warn().
+			Str("user_login", string(hn.login.ID)).
+			Int("conversation_count", missed).
+			Msg("Poll found messages that no webhook announced, speeding up polling")
+	}
+}
+
+// webhookPath returns the path of a webhook endpoint.
+func webhookPath(webhookID string) string {
+	return "/_matrix/mau/hostex/webhook/" + url.PathEscape(webhookID)
+}
+
+// ensureWebhookID returns the webhook ID of this login, generating one if it doesn't have one yet.
+func (hn *HostexNetworkAPI) ensureWebhookID(ctx context.Context) (string, error) {
+	meta := hn.login.Metadata.(*HostexUserLoginMetadata)
+	if meta.WebhookID == "" {
+		meta.WebhookID = random.String(32)
+		if err := hn.login.Save(ctx); err != nil {
+			meta.WebhookID = ""
+			return "", fmt.Errorf("failed to save webhook ID: %w", err)
+		}
+	}
+	return meta.WebhookID, nil
+}
+
+// isOwnWebhook reports whether a registered webhook URL points at this login's endpoint.
+// Only the path is compared, as the public address and the token in the query change
+// with the configuration. Registrations from before webhook IDs used the login ID.
+func (hn *HostexNetworkAPI) isOwnWebhook(webhookURL string) bool {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return false
+	}
+	path := parsed.EscapedPath()
+	webhookID := hn.login.Metadata.(*HostexUserLoginMetadata).WebhookID
+	return (webhookID != "" && strings.HasSuffix(path, webhookPath(webhookID))) ||
+		strings.HasSuffix(path, webhookPath(string(hn.login.ID)))
+}
+
+// webhookURL returns the URL Hostex should deliver events with the given webhook ID to,
+// or an empty string if the bridge has no public address.
+func (hn *HostexNetworkAPI) webhookURL(webhookID string) string {
+	webhookConfig := &hn.connector.Config.Webhook
+	baseURL := webhookConfig.PublicURL
+	if baseURL == "" {
+		if server, ok := hn.br.Matrix.(bridgev2.MatrixConnectorWithServer); ok {
+			baseURL = server.GetPublicAddress()
+		}
+	}
+	if baseURL == "" {
+		return ""
+	}
+	return strings.TrimRight(baseURL, "/") + webhookPath(webhookID) + "?token=" + url.QueryEscape(webhookConfig.Secret)
+}
+
+// ensureWebhook makes sure a webhook pointing at the bridge is registered for this login,
+// replacing registrations with an outdated address or secret. It returns false if webhooks
+// are disabled or registration failed, in which case the caller should rely on polling.
+func (hn *HostexNetworkAPI) ensureWebhook(ctx context.Context) bool {
+	webhookConfig := &hn.connector.Config.Webhook
+	if !webhookConfig.Register {
+		return false
+	}
+	log := hn.br.Log.With().Str("user_login", string(hn.login.ID)).Logger()
+	if webhookConfig.Secret == "" {
+		// Without a secret anyone who finds the URL could trigger syncs
+		log.Warn().Msg("No webhook secret configured, not registering Hostex webhook")
+		return false
+	}
+	webhookID, err := hn.ensureWebhookID(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to assign webhook ID, falling back to polling")
+		return false
+	}
+	webhookURL := hn.webhookURL(webhookID)
+	if webhookURL == "" {
+		log.Warn().Msg("Bridge has no public address, not registering Hostex webhook")
+		return false
+	}
+
+	webhooks, err := hn.client.GetWebhooks(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list Hostex webhooks, falling back to polling")
+		return false
+	}
+	registered := false
+	for _, webhook := range webhooks {
+		if !hn.isOwnWebhook(webhook.URL) {
+			continue
+		} else if webhook.URL == webhookURL && !registered {
+			log.Debug().Int("webhook_id", webhook.ID).Msg("Hostex webhook already registered")
+			registered = true
+			continue
+		}
+		// Stale registrations would deliver events twice or fail authentication
+		if err := hn.client.DeleteWebhook(ctx, webhook.ID); err != nil {
+			log.Warn().Err(err).Int("webhook_id", webhook.ID).Msg("Failed to delete stale Hostex webhook")
+		} else {
+			log.Info().Int("webhook_id", webhook.ID).Msg("Deleted stale Hostex webhook")
+		}
+	}
+	if registered {
+		return true
+	}
+
+	if err := hn.client.CreateWebhook(ctx, webhookURL); err != nil {
+		log.Warn().Err(err).Msg("Failed to register Hostex webhook, falling back to polling")
+		return false
+	}
+	log.Info().Msg("Registered Hostex webhook")
+	return true
+}
+
+// removeWebhook deletes the webhook registrations pointing at this login.
+func (hn *HostexNetworkAPI) removeWebhook(ctx context.Context) {
+	log := hn.br.Log.With().Str("user_login", string(hn.login.ID)).Logger()
+	webhooks, err := hn.client.GetWebhooks(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list Hostex webhooks for removal")
+		return
+	}
+	for _, webhook := range webhooks {
+		if !hn.isOwnWebhook(webhook.URL) {
+			continue
+		}
+		if err := hn.client.DeleteWebhook(ctx, webhook.ID); err != nil {
+			log.Warn().Err(err).Int("webhook_id", webhook.ID).Msg("Failed to delete Hostex webhook")
+		} else {
+			log.Info().Int("webhook_id", webhook.ID).Msg("Deleted Hostex webhook")
+		}
+	}
+}
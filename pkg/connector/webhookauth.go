@@ -0,0 +1,129 @@
+package connector
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	webhookSignatureHeader = "X-Hostex-Signature"
+	webhookTimestampHeader = "X-Hostex-Timestamp"
+	webhookTokenHeader     = "X-Hostex-Webhook-Token"
+
+	// Upper bound for the number of remembered webhook deliveries
+	maxSeenWebhookEvents = 10000
+	// Webhook payloads are small JSON documents, anything larger is rejected unread
+	maxWebhookBodySize = 256 * 1024
+)
+
+var (
+	errWebhookUnauthorized = errors.New("missing or invalid webhook signature")
+	errWebhookStale        = errors.New("webhook timestamp outside the allowed window")
+)
+
+// verifyWebhookSignature checks a delivery against the configured shared secret.
+// Either an HMAC-SHA256 signature of the body (prefixed with the timestamp header
+// and a dot if present) or the secret itself as a token header or query parameter
+// is accepted, since the Hostex dashboard only lets you configure a URL.
+func verifyWebhookSignature(secret string, r *http.Request, body []byte) error {
+	if secret == "" {
+		return nil
+	}
+
+	if signature := r.Header.Get(webhookSignatureHeader); signature != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		if timestamp := r.Header.Get(webhookTimestampHeader); timestamp != "" {
+			mac.Write([]byte(timestamp + "."))
+		}
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		if hmac.Equal([]byte(strings.TrimPrefix(signature, "sha256=")), []byte(expected)) {
+			return nil
+		}
+		return errWebhookUnauthorized
+	}
+
+	token := r.Header.Get(webhookTokenHeader)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1 {
+		return nil
+	}
+	return errWebhookUnauthorized
+}
+
+// checkWebhookTimestamp rejects deliveries whose timestamp is too far from the current time.
+// The timestamp header is preferred over the timestamp in the payload. Deliveries
+// without any timestamp are let through and only protected by deduplication.
+func checkWebhookTimestamp(tolerance time.Duration, r *http.Request, payloadTimestamp time.Time, now time.Time) error {
+	if tolerance <= 0 {
+		return nil
+	}
+	timestamp := payloadTimestamp
+	if header := r.Header.Get(webhookTimestampHeader); header != "" {
+		unix, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			return errWebhookStale
+		}
+		timestamp = time.Unix(unix, 0)
+	}
+	if timestamp.IsZero() {
+		return nil
+	}
+	if diff := now.Sub(timestamp); diff > tolerance || diff < -tolerance {
+		return errWebhookStale
+	}
+	return nil
+}
+
+// webhookDedupe remembers recently seen webhook deliveries to reject replays.
+type webhookDedupe struct {
+	lock sync.Mutex
+	seen *lruCache[struct{}]
+}
+
+func newWebhookDedupe(ttl time.Duration) *webhookDedupe {
+	return &webhookDedupe{seen: newLRUCache[struct{}](maxSeenWebhookEvents, ttl)}
+}
+
+// markSeen records a delivery and reports whether it was already seen within the TTL.
+func (wd *webhookDedupe) markSeen(key string, now time.Time) bool {
+	wd.lock.Lock()
+	defer wd.lock.Unlock()
+
+	// Peek so that replays don't extend how long the delivery is remembered
+	if _, ok := wd.seen.peek(key, now); ok {
+		return true
+	}
+	wd.seen.put(key, struct{}{}, now)
+	return false
+}
+
+// webhookMetrics counts webhook deliveries by outcome for the health endpoint.
+type webhookMetrics struct {
+	Accepted     atomic.Int64
+	Invalid      atomic.Int64
+	Unauthorized atomic.Int64
+	Stale        atomic.Int64
+	Duplicate    atomic.Int64
+}
+
+func (wm *webhookMetrics) snapshot() map[string]int64 {
+	return map[string]int64{
+		"accepted":     wm.Accepted.Load(),
+		"invalid":      wm.Invalid.Load(),
+		"unauthorized": wm.Unauthorized.Load(),
+		"stale":        wm.Stale.Load(),
+		"duplicate":    wm.Duplicate.Load(),
+	}
+}
@@ -0,0 +1,146 @@
+package connector
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	if timestamp != "" {
+		mac.Write([]byte(timestamp + "."))
+	}
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const secret = "hunter2"
+	body := []byte(`{"event":"message_created"}`)
+	tests := []struct {
+		name    string
+		secret  string
+		target  string
+		headers map[string]string
+		wantErr bool
+	}{
+		{"no secret configured", "", "/webhook", nil, false},
+		{"missing credentials", secret, "/webhook", nil, true},
+		{"valid signature", secret, "/webhook", map[string]string{
+			webhookSignatureHeader: signWebhook(secret, "", body),
+		}, false},
+		{"valid prefixed signature", secret, "/webhook", map[string]string{
+			webhookSignatureHeader: "sha256=" + signWebhook(secret, "", body),
+		}, false},
+		{"valid signature with timestamp", secret, "/webhook", map[string]string{
+			webhookSignatureHeader: signWebhook(secret, "1700000000", body),
+			webhookTimestampHeader: "1700000000",
+		}, false},
+		{"signature without the signed timestamp", secret, "/webhook", map[string]string{
+			webhookSignatureHeader: signWebhook(secret, "1700000000", body),
+		}, true},
+		{"signature with the wrong secret", secret, "/webhook", map[string]string{
+			webhookSignatureHeader: signWebhook("wrong", "", body),
+		}, true},
+		{"invalid signature doesn't fall back to token", secret, "/webhook?token=" + secret, map[string]string{
+			webhookSignatureHeader: "deadbeef",
+		}, true},
+		{"valid token header", secret, "/webhook", map[string]string{
+			webhookTokenHeader: secret,
+		}, false},
+		{"valid token query parameter", secret, "/webhook?token=" + secret, nil, false},
+		{"invalid token", secret, "/webhook?token=wrong", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.target, nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			err := verifyWebhookSignature(tt.secret, r, body)
+			if tt.wantErr && !errors.Is(err, errWebhookUnauthorized) {
+				t.Errorf("got %v, want errWebhookUnauthorized", err)
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("got unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckWebhookTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tolerance := 5 * time.Minute
+	tests := []struct {
+		name      string
+		tolerance time.Duration
+		header    string
+		payload   time.Time
+		wantErr   bool
+	}{
+		{"check disabled", 0, "1", time.Time{}, false},
+		{"no timestamp", tolerance, "", time.Time{}, false},
+		{"payload timestamp within window", tolerance, "", now.Add(-time.Minute), false},
+		{"payload timestamp too old", tolerance, "", now.Add(-10 * time.Minute), true},
+		{"payload timestamp too far in the future", tolerance, "", now.Add(10 * time.Minute), true},
+		{"header within window", tolerance, strconv.FormatInt(now.Add(-time.Minute).Unix(), 10), time.Time{}, false},
+		{"header takes precedence over payload", tolerance, strconv.FormatInt(now.Unix(), 10), now.Add(-time.Hour), false},
+		{"stale header", tolerance, strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), now, true},
+		{"malformed header", tolerance, "yesterday", now, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/webhook", nil)
+			if tt.header != "" {
+				r.Header.Set(webhookTimestampHeader, tt.header)
+			}
+			err := checkWebhookTimestamp(tt.tolerance, r, tt.payload, now)
+			if tt.wantErr && !errors.Is(err, errWebhookStale) {
+				t.Errorf("got %v, want errWebhookStale", err)
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("got unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhookDedupe(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ttl := 10 * time.Minute
+	wd := newWebhookDedupe(ttl)
+
+	if wd.markSeen("a", now) {
+		t.Fatal("first delivery reported as seen")
+	}
+	if !wd.markSeen("a", now.Add(time.Minute)) {
+		t.Fatal("repeated delivery not reported as seen")
+	}
+	if wd.markSeen("b", now.Add(time.Minute)) {
+		t.Fatal("different delivery reported as seen")
+	}
+	if !wd.markSeen("a", now.Add(ttl-time.Second)) {
+		t.Fatal("repeated delivery not reported as seen within the window")
+	}
+	if wd.markSeen("a", now.Add(ttl+time.Second)) {
+		t.Fatal("delivery reported as seen after the window expired")
+	}
+}
+
+func TestWebhookDedupeBounded(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	wd := newWebhookDedupe(time.Hour)
+	for i := range maxSeenWebhookEvents + 10 {
+		wd.markSeen(strconv.Itoa(i), now.Add(time.Duration(i)*time.Millisecond))
+	}
+	if len(wd.seen.items) > maxSeenWebhookEvents {
+		t.Fatalf("remembered %d deliveries, limit is %d", len(wd.seen.items), maxSeenWebhookEvents)
+	}
+	if _, ok := wd.seen.items["0"]; ok {
+		t.Fatal("oldest delivery wasn't evicted")
+	}
+}
@@ -0,0 +1,96 @@
+package hostexapi
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// AttachmentURLs are the URLs of an image attachment in different sizes.
+type AttachmentURLs struct {
+	Original string `json:"original"`
+	XLarge   string `json:"xlarge"`
+	Large    string `json:"large"`
+	Medium   string `json:"medium"`
+	Small    string `json:"small"`
+}
+
+// Attachment is a file or image attached to a message.
+type Attachment struct {
+	// Type is "image" for images, other attachments are files
+	Type string `json:"type"`
+	// FallbackURL is the URL of the attachment at the largest available size.
+	// The field is spelled "fullback_url" in the Hostex API.
+	FallbackURL string         `json:"fullback_url"`
+	URL         string         `json:"url"`
+	URLs        AttachmentURLs `json:"urls"`
+	Filename    string         `json:"filename"`
+	MimeType    string         `json:"mime_type"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+}
+
+// UnmarshalJSON decodes an attachment object. Older messages have a plain URL string instead,
+// which is decoded as an image attachment with only the URL set.
+func (a *Attachment) UnmarshalJSON(data []byte) error {
+	var attachmentURL string
+	if err := json.Unmarshal(data, &attachmentURL); err == nil {
+		*a = Attachment{Type: "image", URL: attachmentURL}
+		return nil
+	}
+	type rawAttachment Attachment
+	return json.Unmarshal(data, (*rawAttachment)(a))
+}
+
+// HasAttachment reports whether the message has an attachment with a URL.
+func (m *Message) HasAttachment() bool {
+	return m.Attachment != nil && m.Attachment.BestURL() != ""
+}
+
+// IsImage reports whether the attachment is an image.
+func (a *Attachment) IsImage() bool {
+	return a.Type == "image" || strings.HasPrefix(a.MimeType, "image/")
+}
+
+// BestURL returns the URL of the largest available version of the attachment.
+func (a *Attachment) BestURL() string {
+	for _, attachmentURL := range []string{a.URLs.Original, a.FallbackURL, a.URLs.XLarge, a.URLs.Large, a.URL, a.URLs.Medium, a.URLs.Small} {
+		if attachmentURL != "" {
+			return attachmentURL
+		}
+	}
+	return ""
+}
+
+// imageSizeSuffixes are the path suffixes Hostex uses to serve images in different
+// sizes, e.g. https://.../RQX1754769570578.jpeg/xlarge
+var imageSizeSuffixes = map[string]bool{"xlarge": true, "large": true, "medium": true, "small": true}
+
+// GetFilename returns the filename of the attachment, deriving it from the URL if Hostex didn't provide one.
+func (a *Attachment) GetFilename() string {
+	if a.Filename != "" {
+		return a.Filename
+	}
+	attachmentURL, _, _ := strings.Cut(a.BestURL(), "?")
+	dir, name := path.Split(attachmentURL)
+	if imageSizeSuffixes[name] {
+		name = path.Base(dir)
+	}
+	if name != "" && name != "." && name != "/" && strings.Contains(name, ".") {
+		return name
+	} else if a.IsImage() {
+		return "image.jpg"
+	}
+	return "attachment"
+}
+
+// GetMimeType returns the MIME type of the attachment, or an empty string if it's unknown.
+func (a *Attachment) GetMimeType() string {
+	if a.MimeType != "" {
+		return a.MimeType
+	} else if a.IsImage() {
+		// Hostex serves images as JPEG
+		return "image/jpeg"
+	}
+	return ""
+}
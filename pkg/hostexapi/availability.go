@@ -0,0 +1,82 @@
+package hostexapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Availability is whether a property can be booked on a date.
+type Availability struct {
+	// Date is formatted as YYYY-MM-DD
+	Date      string `json:"date"`
+	Available bool   `json:"available"`
+}
+
+// PropertyAvailability is the availability calendar of a single property.
+type PropertyAvailability struct {
+	PropertyID     int            `json:"property_id"`
+	Availabilities []Availability `json:"availabilities"`
+}
+
+type AvailabilitiesResponse struct {
+	Listings []PropertyAvailability `json:"listings"`
+}
+
+func joinPropertyIDs(propertyIDs []int) string {
+	ids := make([]string, len(propertyIDs))
+	for i, id := range propertyIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	return strings.Join(ids, ",")
+}
+
+// GetAvailabilities fetches the availability of the given properties between two dates (inclusive).
+func (c *Client) GetAvailabilities(ctx context.Context, propertyIDs []int, startDate, endDate time.Time) ([]PropertyAvailability, error) {
+	if len(propertyIDs) == 0 {
+		return nil, fmt.Errorf("no properties given")
+	}
+	query := url.Values{}
+	query.Set("property_ids", joinPropertyIDs(propertyIDs))
+	query.Set("start_date", startDate.Format(time.DateOnly))
+	query.Set("end_date", endDate.Format(time.DateOnly))
+	resp, err := doRequest[AvailabilitiesResponse](ctx, c, "GET", "/availabilities?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data.Listings, nil
+}
+
+// GetPropertyAvailability fetches the availability of a single property between two dates (inclusive).
+func (c *Client) GetPropertyAvailability(ctx context.Context, propertyID int, startDate, endDate time.Time) ([]Availability, error) {
+	listings, err := c.GetAvailabilities(ctx, []int{propertyID}, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	for _, listing := range listings {
+		if listing.PropertyID == propertyID {
+			return listing.Availabilities, nil
+		}
+	}
+	return nil, nil
+}
+
+// UpdateAvailability opens (available=true) or blocks (available=false) the given properties
+// between two dates (inclusive). Dates that are booked can't be opened.
+func (c *Client) UpdateAvailability(ctx context.Context, propertyIDs []int, startDate, endDate time.Time, available bool) error {
+	if len(propertyIDs) == 0 {
+		return fmt.Errorf("no properties given")
+	}
+	// Setting availability to an absolute value is safe to repeat
+	_, err := doIdempotentRequest[json.RawMessage](ctx, c, "POST", "/availabilities", map[string]interface{}{
+		"property_ids": propertyIDs,
+		"start_date":   startDate.Format(time.DateOnly),
+		"end_date":     endDate.Format(time.DateOnly),
+		"available":    available,
+	})
+	return err
+}
@@ -4,9 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/rs/zerolog"
 )
 
 const (
@@ -18,15 +25,34 @@ type Client struct {
 	httpClient  *http.Client
 	accessToken string
 	baseURL     string
+	userAgent   string
+	log         zerolog.Logger
+	// diagnostics enables logging request and response bodies, which contain guest messages and contact details
+	diagnostics bool
 }
 
-type APIResponse struct {
+// ResponseStatus contains the fields that every Hostex API response has next to its data.
+type ResponseStatus struct {
 	RequestID string      `json:"request_id"`
-	Data      interface{} `json:"data,omitempty"`
 	ErrorCode interface{} `json:"error_code,omitempty"`
 	ErrorMsg  string      `json:"error_msg,omitempty"`
 }
 
+func (rs *ResponseStatus) status() *ResponseStatus {
+	return rs
+}
+
+// APIResponse is the envelope of Hostex API responses, with the data decoded as T.
+type APIResponse[T any] struct {
+	ResponseStatus
+	Data T `json:"data"`
+}
+
+// apiEnvelope is implemented by all APIResponse types.
+type apiEnvelope interface {
+	status() *ResponseStatus
+}
+
 type Property struct {
 	ID                  int     `json:"id"`
 	Title               string  `json:"title"`
@@ -38,19 +64,8 @@ type Property struct {
 	WifiPassword        string  `json:"wifi_password"`
 	Latitude            float64 `json:"latitude"`
 	Longitude           float64 `json:"longitude"`
-}
-
-type Reservation struct {
-	ReservationCode string `json:"reservation_code"`
-	PropertyID      int    `json:"property_id"`
-	GuestName       string `json:"guest_name"`
-	GuestEmail      string `json:"guest_email"`
-	GuestPhone      string `json:"guest_phone"`
-	CheckInDate     string `json:"check_in_date"`
-	CheckOutDate    string `json:"check_out_date"`
-	Status          string `json:"status"`
-	ConversationID  string `json:"conversation_id"`
-	ChannelType     string `json:"channel_type"`
+	// Channels are the listings of the property on booking channels like Airbnb
+	Channels []ListingRef `json:"channels"`
 }
 
 type Conversation struct {
@@ -74,21 +89,40 @@ type Message struct {
 	SenderRole  string      `json:"sender_role"` // "guest" or "host"
 	DisplayType string      `json:"display_type"`
 	Content     string      `json:"content"`
-	Attachment  interface{} `json:"attachment"`
+	Attachment  *Attachment `json:"attachment"`
 	CreatedAt   time.Time   `json:"created_at"`
 }
 
-func NewClient(accessToken string) *Client {
-	return &Client{
+func NewClient(accessToken string, opts ...ClientOption) *Client {
+	c := &Client{
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second, // Reduced timeout to catch hanging requests
+			Timeout: DefaultTimeout, // Reduced timeout to catch hanging requests
 		},
 		accessToken: accessToken,
 		baseURL:     BaseURL,
+		userAgent:   UserAgent,
+		log:         zerolog.Nop(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
-func (c *Client) doRequest(ctx context.Context, method, endpoint string, body interface{}) (*APIResponse, error) {
+// doRequest sends a request to the Hostex API and decodes the response data as T, retrying
+// with exponential backoff on network errors, server errors and rate limits. Requests that
+// aren't safe to repeat are only retried if they were rate limited or couldn't reach the server at all.
+func doRequest[T any](ctx context.Context, c *Client, method, endpoint string, body interface{}) (*APIResponse[T], error) {
+	return doRequestWithRetries[T](ctx, c, method, endpoint, body, isIdempotent(method))
+}
+
+// doIdempotentRequest is like doRequest, but also retries server errors and network errors for
+// POST requests that are safe to repeat, like queries and updates that set absolute values.
+func doIdempotentRequest[T any](ctx context.Context, c *Client, method, endpoint string, body interface{}) (*APIResponse[T], error) {
+	return doRequestWithRetries[T](ctx, c, method, endpoint, body, true)
+}
+
+func doRequestWithRetries[T any](ctx context.Context, c *Client, method, endpoint string, body interface{}, idempotent bool) (*APIResponse[T], error) {
 	var reqBody []byte
 	var err error
 
@@ -99,36 +133,124 @@ func (c *Client) doRequest(ctx context.Context, method, endpoint string, body in
 		}
 	}
 
+	for attempt := 1; ; attempt++ {
+		var apiResp APIResponse[T]
+		retry, retryAfter, err := c.doRequestOnce(ctx, method, endpoint, reqBody, idempotent, &apiResp)
+		if err == nil {
+			return &apiResp, nil
+		} else if !retry || attempt >= DefaultMaxAttempts || retryAfter > retryMaxRetryAfter {
+			return nil, err
+		} else if retryAfter <= 0 {
+			retryAfter = retryBackoff(attempt)
+		}
+		c.log.Debug().Err(err).
+			Str("method", method).
+			Str("path", endpointPath(endpoint)).
+			Int("attempt", attempt).
+			Dur("retry_in", retryAfter).
+			Msg("Hostex API request failed, retrying")
+		select {
+		case <-time.After(retryAfter):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// doRequestOnce makes a single attempt of a request and decodes the response into apiResp.
+// If it fails, it also reports whether the request may be retried and how long the server
+// asked to wait before doing so.
+func (c *Client) doRequestOnce(ctx context.Context, method, endpoint string, reqBody []byte, idempotent bool, apiResp apiEnvelope) (retry bool, retryAfter time.Duration, err error) {
 	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+endpoint, bytes.NewReader(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return false, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("User-Agent", c.userAgent)
 	req.Header.Set("Hostex-Access-Token", c.accessToken)
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		if ctx.Err() != nil {
+			return false, 0, ctx.Err()
+		}
+		return idempotent || isConnectError(err), 0, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var apiResp APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return idempotent, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	decodeErr := json.Unmarshal(respBody, apiResp)
+	status := apiResp.status()
+	if decodeErr != nil {
+		// The data of error responses may not match the expected type, so decode just the status.
+		// Error responses from proxies and load balancers may not be JSON at all.
+		status = &ResponseStatus{}
+		if json.Unmarshal(respBody, status) != nil {
+			status = nil
+		}
+	}
+	var requestID string
+	if status != nil {
+		requestID = status.RequestID
 	}
+	c.logRequest(method, endpoint, reqBody, resp.StatusCode, time.Since(start), requestID, respBody)
 
-	// Check if there's actually an error (error_code != 200)
-	if apiResp.ErrorCode != nil && apiResp.ErrorCode != "" {
-		// Convert error code to check if it's not 200 (success)
-		errorCodeStr := fmt.Sprintf("%v", apiResp.ErrorCode)
-		if errorCodeStr != "200" {
-			return &apiResp, fmt.Errorf("API error %v: %s", apiResp.ErrorCode, apiResp.ErrorMsg)
+	// Hostex reports some errors with HTTP 200 and error_code != 200 in the body
+	var errorCode int
+	if status != nil {
+		errorCode = parseErrorCode(status.ErrorCode)
+	}
+	if resp.StatusCode < 400 && (errorCode == 0 || errorCode == 200) {
+		if decodeErr != nil {
+			return false, 0, fmt.Errorf("failed to decode response: %w", decodeErr)
 		}
+		return false, 0, nil
 	}
 
-	return &apiResp, nil
+	retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	apiErr := newAPIError(resp.StatusCode, status)
+	switch {
+	case errors.Is(apiErr, ErrRateLimited):
+		// Rate limited requests weren't processed, so they can always be repeated
+		return true, retryAfter, apiErr
+	case apiErr.StatusCode >= 500 || apiErr.ErrorCode >= 500:
+		return idempotent, retryAfter, apiErr
+	default:
+		return false, 0, apiErr
+	}
+}
+
+// logRequest logs a completed request at debug level. Bodies and query parameters may contain
+// guest messages, phone numbers and email addresses, so they're only logged in diagnostics mode.
+func (c *Client) logRequest(method, endpoint string, reqBody []byte, status int, duration time.Duration, requestID string, respBody []byte) {
+	evt := c.log.Debug()
+	if !evt.Enabled() {
+		return
+	}
+	evt = evt.
+		Str("method", method).
+		Str("path", endpointPath(endpoint)).
+		Int("status", status).
+		Dur("duration", duration).
+		Str("request_id", requestID)
+	if c.diagnostics {
+		evt = evt.
+			Str("endpoint", endpoint).
+			Bytes("request_body", reqBody).
+			Bytes("response_body", respBody)
+	}
+	evt.Msg("Hostex API request")
+}
+
+// endpointPath strips the query parameters from an endpoint.
+func endpointPath(endpoint string) string {
+	path, _, _ := strings.Cut(endpoint, "?")
+	return path
 }
 
 type PropertiesResponse struct {
@@ -140,10 +262,6 @@ type ConversationsResponse struct {
 	Conversations []Conversation `json:"conversations"`
 }
 
-type ReservationsResponse struct {
-	Reservations []Reservation `json:"reservations"`
-}
-
 type ConversationDetails struct {
 	ID          string     `json:"id"`
 	ChannelType string     `json:"channel_type"`
@@ -169,111 +287,61 @@ type ActivityProperty struct {
 	RoomType *string `json:"room_type"`
 }
 
-type ConversationDetailsResponse struct {
-	ConversationDetails ConversationDetails `json:"data"`
-}
-
-func (c *Client) GetProperties(ctx context.Context) ([]Property, error) {
-	resp, err := c.doRequest(ctx, "GET", "/properties", nil)
+func (c *Client) fetchProperties(ctx context.Context, opts ListOptions) ([]Property, int, error) {
+	resp, err := doRequest[PropertiesResponse](ctx, c, "GET", "/properties?"+opts.values().Encode(), nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-
-	// Marshal the interface{} back to JSON, then unmarshal to our struct
-	dataBytes, err := json.Marshal(resp.Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response data: %w", err)
-	}
-
-	var propertiesResp PropertiesResponse
-	if err := json.Unmarshal(dataBytes, &propertiesResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal properties response: %w", err)
-	}
-
-	return propertiesResp.Properties, nil
+	return resp.Data.Properties, resp.Data.Total, nil
 }
 
-func (c *Client) GetReservations(ctx context.Context, propertyID string) ([]Reservation, error) {
-	endpoint := "/reservations?offset=0&limit=50"
-	if propertyID != "" {
-		endpoint += "&property_id=" + propertyID
-	}
-
-	resp, err := c.doRequest(ctx, "GET", endpoint, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// Marshal the interface{} back to JSON, then unmarshal to our struct
-	dataBytes, err := json.Marshal(resp.Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response data: %w", err)
-	}
+// ListProperties fetches a single page of properties.
+func (c *Client) ListProperties(ctx context.Context, opts ListOptions) (*Page[Property], error) {
+	return fetchPage(ctx, opts, c.fetchProperties)
+}
 
-	var reservationsResp ReservationsResponse
-	if err := json.Unmarshal(dataBytes, &reservationsResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal reservations response: %w", err)
-	}
+// IterateProperties yields every property starting at opts.Offset, fetching pages as needed.
+func (c *Client) IterateProperties(ctx context.Context, opts ListOptions) iter.Seq2[Property, error] {
+	return paginate(ctx, opts, c.fetchProperties)
+}
 
-	return reservationsResp.Reservations, nil
+// GetProperties fetches all properties of the account.
+func (c *Client) GetProperties(ctx context.Context) ([]Property, error) {
+	return collect(c.IterateProperties(ctx, ListOptions{Limit: MaxPageSize}))
 }
 
-func (c *Client) GetConversations(ctx context.Context) ([]Conversation, error) {
+func (c *Client) fetchConversations(ctx context.Context, opts ListOptions) ([]Conversation, int, error) {
 	// Conversations API requires offset parameter
-	resp, err := c.doRequest(ctx, "GET", "/conversations?offset=0&limit=50", nil)
+	resp, err := doRequest[ConversationsResponse](ctx, c, "GET", "/conversations?"+opts.values().Encode(), nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
+	// The conversations endpoint doesn't report a total, so pagination stops at the first short page
+	return resp.Data.Conversations, -1, nil
+}
 
-	// Marshal the interface{} back to JSON, then unmarshal to our struct
-	dataBytes, err := json.Marshal(resp.Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response data: %w", err)
-	}
+// ListConversations fetches a single page of conversations, most recently active first.
+func (c *Client) ListConversations(ctx context.Context, opts ListOptions) (*Page[Conversation], error) {
+	return fetchPage(ctx, opts, c.fetchConversations)
+}
 
-	var conversationsResp ConversationsResponse
-	if err := json.Unmarshal(dataBytes, &conversationsResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal conversations response: %w", err)
-	}
+// IterateConversations yields every conversation starting at opts.Offset, most recently active first.
+// Callers can stop early by breaking out of the loop, which avoids fetching further pages.
+func (c *Client) IterateConversations(ctx context.Context, opts ListOptions) iter.Seq2[Conversation, error] {
+	return paginate(ctx, opts, c.fetchConversations)
+}
 
-	return conversationsResp.Conversations, nil
+// GetConversations fetches all conversations of the account.
+func (c *Client) GetConversations(ctx context.Context) ([]Conversation, error) {
+	return collect(c.IterateConversations(ctx, ListOptions{Limit: MaxPageSize}))
 }
 
 func (c *Client) GetConversationDetails(ctx context.Context, conversationID string) (*ConversationDetails, error) {
-	// Debug logging removed to avoid leaking secrets
-	resp, err := c.doRequest(ctx, "GET", "/conversations/"+conversationID, nil)
+	resp, err := doRequest[ConversationDetails](ctx, c, "GET", "/conversations/"+url.PathEscape(conversationID), nil)
 	if err != nil {
 		return nil, err
 	}
-
-	// Marshal the interface{} back to JSON, then unmarshal to our struct
-	dataBytes, err := json.Marshal(resp.Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response data: %w", err)
-	}
-
-	var detailsResp ConversationDetails
-	if err := json.Unmarshal(dataBytes, &detailsResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal conversation details: %w", err)
-	}
-
-	// Debug: Log message count and latest message times
-	fmt.Printf("DEBUG: Got %d messages for conversation %s\n", len(detailsResp.Messages), conversationID)
-	if len(detailsResp.Messages) > 0 {
-		latestMsg := detailsResp.Messages[0] // Messages are in reverse chronological order
-		fmt.Printf("DEBUG: Latest message: '%s' from %s at %s\n", latestMsg.Content, latestMsg.SenderRole, latestMsg.CreatedAt.String())
-
-		// Log the first few messages to compare with curl output
-		fmt.Printf("DEBUG: First 3 messages:\n")
-		for i, msg := range detailsResp.Messages {
-			if i >= 3 {
-				break
-			}
-			fmt.Printf("  %d: '%s' from %s at %s\n", i+1, msg.Content, msg.SenderRole, msg.CreatedAt.String())
-		}
-	}
-
-	return &detailsResp, nil
+	return &resp.Data, nil
 }
 
 // GetMessages - Messages endpoint is not available in the Hostex API
@@ -304,14 +372,22 @@ func (c *Client) SendMessageWithImage(ctx context.Context, conversationID, conte
 		return nil, fmt.Errorf("must provide either message content or jpeg image")
 	}
 
-	_, err := c.doRequest(ctx, "POST", "/conversations/"+conversationID, payload)
+	sentAt := time.Now()
+	_, err := doRequest[json.RawMessage](ctx, c, "POST", "/conversations/"+url.PathEscape(conversationID), payload)
 	if err != nil {
 		return nil, err
 	}
 
-	// The API doesn't return message data, just success/failure
-	// So we'll create a mock message object for the bridge to use
-	now := time.Now()
+	// The API doesn't return message data, just success/failure,
+	// so look up the message we just sent in the conversation history
+	sentMessage, err := c.findSentMessage(ctx, conversationID, content, jpegBase64 != "", sentAt)
+	if err != nil {
+		return nil, err
+	} else if sentMessage != nil {
+		return sentMessage, nil
+	}
+
+	// The message didn't show up in the history yet, so return a placeholder with a pending ID
 	displayType := "Text"
 	if jpegBase64 != "" {
 		if content != "" {
@@ -322,13 +398,68 @@ func (c *Client) SendMessageWithImage(ctx context.Context, conversationID, conte
 	}
 
 	mockMessage := &Message{
-		ID:          fmt.Sprintf("sent-%d", now.Unix()),
+		ID:          PendingMessageID(sentAt),
 		SenderRole:  "host",
 		DisplayType: displayType,
 		Content:     content,
-		Attachment:  nil, // Could store image info here if needed
-		CreatedAt:   now,
+		CreatedAt:   sentAt,
 	}
 
 	return mockMessage, nil
 }
+
+// MaxClockSkew is the allowed difference between the local time a message was sent
+// and the timestamp Hostex reports for it.
+const MaxClockSkew = 2 * time.Minute
+
+const (
+	pendingMessageIDPrefix    = "sent-"
+	sentMessageLookupAttempts = 3
+	sentMessageLookupDelay    = time.Second
+	// Upper bound for the whole lookup including retries of the individual requests,
+	// as the caller is waiting for it to finish
+	sentMessageLookupTimeout = 5 * time.Second
+)
+
+// PendingMessageID returns a placeholder ID for a sent message whose real ID isn't known yet.
+func PendingMessageID(sentAt time.Time) string {
+	return fmt.Sprintf("%s%d", pendingMessageIDPrefix, sentAt.UnixNano())
+}
+
+// IsPendingMessageID checks whether a message ID is a placeholder returned by SendMessageWithImage.
+func IsPendingMessageID(messageID string) bool {
+	return strings.HasPrefix(messageID, pendingMessageIDPrefix)
+}
+
+// findSentMessage looks up a message that was just sent in the conversation history.
+// Hostex may take a moment to list new messages, so the lookup is retried a few times.
+// It returns nil without an error if the message couldn't be found.
+func (c *Client) findSentMessage(ctx context.Context, conversationID, content string, hasImage bool, sentAt time.Time) (*Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, sentMessageLookupTimeout)
+	defer cancel()
+	content = strings.TrimSpace(content)
+	for attempt := 0; attempt < sentMessageLookupAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(sentMessageLookupDelay):
+			case <-ctx.Done():
+				return nil, nil
+			}
+		}
+		details, err := c.GetConversationDetails(ctx, conversationID)
+		if err != nil {
+			// The message was sent successfully, so a failed lookup isn't fatal
+			return nil, nil
+		}
+		// Messages are in reverse chronological order, so the newest match is found first
+		for _, msg := range details.Messages {
+			if msg.CreatedAt.Before(sentAt.Add(-MaxClockSkew)) {
+				break
+			}
+			if msg.SenderRole == "host" && strings.TrimSpace(msg.Content) == content && msg.HasAttachment() == hasImage {
+				return &msg, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,88 @@
+package hostexapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+var (
+	// ErrUnauthorized is matched by API errors caused by a missing, invalid or revoked access token
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrRateLimited is matched by API errors caused by exceeding the Hostex rate limit
+	ErrRateLimited = errors.New("rate limited")
+	// ErrNotFound is matched by API errors for resources that don't exist (anymore)
+	ErrNotFound = errors.New("not found")
+)
+
+// APIError is returned for requests that the Hostex API rejected, either with an HTTP
+// error status or with an error_code other than 200 in the response body.
+//
+// Use errors.Is with ErrUnauthorized, ErrRateLimited or ErrNotFound to check the kind of error.
+type APIError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	// ErrorCode is the error_code from the response body, or 0 if there wasn't one
+	ErrorCode int
+	Message   string
+	// RequestID is the request_id from the response body, which Hostex support can use to find the request
+	RequestID string
+}
+
+func newAPIError(statusCode int, status *ResponseStatus) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+	if status != nil {
+		apiErr.ErrorCode = parseErrorCode(status.ErrorCode)
+		apiErr.Message = status.ErrorMsg
+		apiErr.RequestID = status.RequestID
+	}
+	return apiErr
+}
+
+// parseErrorCode converts the error_code field, which may be a number or a string, to an int.
+func parseErrorCode(code interface{}) int {
+	switch typed := code.(type) {
+	case float64:
+		return int(typed)
+	case string:
+		parsed, _ := strconv.Atoi(typed)
+		return parsed
+	default:
+		return 0
+	}
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	errStr := fmt.Sprintf("Hostex API error (HTTP %d", e.StatusCode)
+	if e.ErrorCode != 0 {
+		errStr += fmt.Sprintf(", code %d", e.ErrorCode)
+	}
+	errStr += "): " + msg
+	if e.RequestID != "" {
+		errStr += " (request ID: " + e.RequestID + ")"
+	}
+	return errStr
+}
+
+// hasCode reports whether either the HTTP status or the Hostex error code is the given status.
+func (e *APIError) hasCode(code int) bool {
+	return e.StatusCode == code || e.ErrorCode == code
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.hasCode(http.StatusUnauthorized) || e.hasCode(http.StatusForbidden)
+	case ErrRateLimited:
+		return e.hasCode(http.StatusTooManyRequests)
+	case ErrNotFound:
+		return e.hasCode(http.StatusNotFound)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,66 @@
+package hostexapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          *APIError
+		unauthorized bool
+		rateLimited  bool
+		notFound     bool
+	}{
+		{"http 401", &APIError{StatusCode: http.StatusUnauthorized}, true, false, false},
+		{"http 403", &APIError{StatusCode: http.StatusForbidden}, true, false, false},
+		{"http 429", &APIError{StatusCode: http.StatusTooManyRequests}, false, true, false},
+		{"http 404", &APIError{StatusCode: http.StatusNotFound}, false, false, true},
+		{"error code 401 in a 200 response", &APIError{StatusCode: http.StatusOK, ErrorCode: 401}, true, false, false},
+		{"error code 429 in a 200 response", &APIError{StatusCode: http.StatusOK, ErrorCode: 429}, false, true, false},
+		{"server error", &APIError{StatusCode: http.StatusInternalServerError}, false, false, false},
+		{"unrelated error code", &APIError{StatusCode: http.StatusBadRequest, ErrorCode: 1001}, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Callers usually get the error wrapped with more context
+			err := fmt.Errorf("failed to do something: %w", tt.err)
+			if got := errors.Is(err, ErrUnauthorized); got != tt.unauthorized {
+				t.Errorf("errors.Is(ErrUnauthorized) = %v, want %v", got, tt.unauthorized)
+			}
+			if got := errors.Is(err, ErrRateLimited); got != tt.rateLimited {
+				t.Errorf("errors.Is(ErrRateLimited) = %v, want %v", got, tt.rateLimited)
+			}
+			if got := errors.Is(err, ErrNotFound); got != tt.notFound {
+				t.Errorf("errors.Is(ErrNotFound) = %v, want %v", got, tt.notFound)
+			}
+		})
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name string
+		code interface{}
+		want int
+	}{
+		{"numeric code", float64(429), 429},
+		{"string code", "404", 404},
+		{"invalid string code", "oops", 0},
+		{"missing code", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := newAPIError(http.StatusOK, &ResponseStatus{RequestID: "req", ErrorCode: tt.code, ErrorMsg: "msg"})
+			if apiErr.ErrorCode != tt.want {
+				t.Errorf("got error code %d, want %d", apiErr.ErrorCode, tt.want)
+			}
+			if apiErr.RequestID != "req" || apiErr.Message != "msg" {
+				t.Errorf("request ID or message not copied: %+v", apiErr)
+			}
+		})
+	}
+}
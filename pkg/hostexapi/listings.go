@@ -0,0 +1,77 @@
+package hostexapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ListingRef identifies a listing of a property on a booking channel.
+type ListingRef struct {
+	ChannelType string `json:"channel_type"`
+	ListingID   string `json:"listing_id"`
+	// Currency is only set in property channel lists
+	Currency string `json:"currency,omitempty"`
+}
+
+// ListingCalendarDay is the price and inventory of a listing on a date.
+type ListingCalendarDay struct {
+	// Date is formatted as YYYY-MM-DD
+	Date      string  `json:"date"`
+	Price     float64 `json:"price"`
+	Inventory int     `json:"inventory"`
+}
+
+// ListingCalendar is the price calendar of a single listing.
+type ListingCalendar struct {
+	ChannelType string               `json:"channel_type"`
+	ListingID   string               `json:"listing_id"`
+	Calendar    []ListingCalendarDay `json:"calendar"`
+}
+
+type ListingCalendarsResponse struct {
+	Listings []ListingCalendar `json:"listings"`
+}
+
+// ListingPrice is the nightly price of a listing between two dates (inclusive).
+type ListingPrice struct {
+	StartDate string  `json:"start_date"`
+	EndDate   string  `json:"end_date"`
+	Price     float64 `json:"price"`
+}
+
+// GetListingCalendars fetches the prices and inventory of listings between two dates (inclusive).
+func (c *Client) GetListingCalendars(ctx context.Context, listings []ListingRef, startDate, endDate time.Time) ([]ListingCalendar, error) {
+	if len(listings) == 0 {
+		return nil, fmt.Errorf("no listings given")
+	}
+	refs := make([]ListingRef, len(listings))
+	for i, listing := range listings {
+		refs[i] = ListingRef{ChannelType: listing.ChannelType, ListingID: listing.ListingID}
+	}
+	// The listing calendar is queried with POST, but it's read-only, so it's safe to repeat
+	resp, err := doIdempotentRequest[ListingCalendarsResponse](ctx, c, "POST", "/listings/calendar", map[string]interface{}{
+		"start_date": startDate.Format(time.DateOnly),
+		"end_date":   endDate.Format(time.DateOnly),
+		"listings":   refs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data.Listings, nil
+}
+
+// UpdateListingPrices sets the nightly prices of a listing for one or more date ranges.
+func (c *Client) UpdateListingPrices(ctx context.Context, listing ListingRef, prices []ListingPrice) error {
+	if len(prices) == 0 {
+		return fmt.Errorf("no prices given")
+	}
+	// Setting prices to absolute values is safe to repeat
+	_, err := doIdempotentRequest[json.RawMessage](ctx, c, "POST", "/listings/prices", map[string]interface{}{
+		"channel_type": listing.ChannelType,
+		"listing_id":   listing.ListingID,
+		"prices":       prices,
+	})
+	return err
+}
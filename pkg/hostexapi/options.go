@@ -0,0 +1,83 @@
+package hostexapi
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultTimeout is the timeout of a single request attempt
+const DefaultTimeout = 10 * time.Second
+
+// ClientOption configures a Client created with NewClient.
+type ClientOption func(*Client)
+
+// WithBaseURL makes the client use a different API endpoint, such as a staging server or a local mock.
+// An empty URL keeps the default.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimSuffix(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used for requests.
+// The client's timeout is used as is unless WithTimeout is also given.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithTransport sets the round tripper of the HTTP client used for requests.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.copyHTTPClient().Transport = transport
+	}
+}
+
+// WithTimeout sets the timeout of a single request attempt. Zero or negative values keep the default.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.copyHTTPClient().Timeout = timeout
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests. An empty user agent keeps the default.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		if userAgent != "" {
+			c.userAgent = userAgent
+		}
+	}
+}
+
+// WithLogger sets the logger used for request logging.
+func WithLogger(log zerolog.Logger) ClientOption {
+	return func(c *Client) {
+		c.log = log
+	}
+}
+
+// WithDiagnostics enables logging full request and response bodies. They contain guest messages,
+// phone numbers and email addresses, so this should only be used for debugging.
+func WithDiagnostics(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.diagnostics = enabled
+	}
+}
+
+// copyHTTPClient replaces the HTTP client with a copy, so that options don't modify
+// a client passed to WithHTTPClient that may be shared with other code.
+func (c *Client) copyHTTPClient() *http.Client {
+	httpClient := *c.httpClient
+	c.httpClient = &httpClient
+	return c.httpClient
+}
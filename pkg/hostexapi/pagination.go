@@ -0,0 +1,109 @@
+package hostexapi
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+const (
+	// DefaultPageSize is used when ListOptions.Limit is not set
+	DefaultPageSize = 50
+	// MaxPageSize is the largest page the Hostex API accepts for list endpoints
+	MaxPageSize = 100
+)
+
+// ListOptions controls offset pagination for list endpoints.
+type ListOptions struct {
+	Offset int
+	Limit  int
+}
+
+func (o ListOptions) normalize() ListOptions {
+	if o.Offset < 0 {
+		o.Offset = 0
+	}
+	if o.Limit <= 0 {
+		o.Limit = DefaultPageSize
+	} else if o.Limit > MaxPageSize {
+		o.Limit = MaxPageSize
+	}
+	return o
+}
+
+func (o ListOptions) values() url.Values {
+	o = o.normalize()
+	query := url.Values{}
+	query.Set("offset", strconv.Itoa(o.Offset))
+	query.Set("limit", strconv.Itoa(o.Limit))
+	return query
+}
+
+// Page is a single page of results from a list endpoint.
+// Total is only filled for endpoints that report it, otherwise it is -1.
+type Page[T any] struct {
+	Items   []T
+	Offset  int
+	Total   int
+	HasMore bool
+}
+
+// pageFetcher fetches one page. It returns the items and the total item count, or -1 if unknown.
+type pageFetcher[T any] func(ctx context.Context, opts ListOptions) ([]T, int, error)
+
+func fetchPage[T any](ctx context.Context, opts ListOptions, fetch pageFetcher[T]) (*Page[T], error) {
+	opts = opts.normalize()
+	items, total, err := fetch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	hasMore := len(items) >= opts.Limit
+	if total >= 0 {
+		hasMore = opts.Offset+len(items) < total && len(items) > 0
+	}
+	return &Page[T]{
+		Items:   items,
+		Offset:  opts.Offset,
+		Total:   total,
+		HasMore: hasMore,
+	}, nil
+}
+
+// paginate yields every item of an offset-paginated endpoint starting at opts.Offset.
+// Iteration stops after the first error, which is yielded with a zero item.
+func paginate[T any](ctx context.Context, opts ListOptions, fetch pageFetcher[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		// Each iteration gets its own copy, so the sequence can be ranged over again or concurrently
+		o := opts.normalize()
+		for {
+			page, err := fetchPage(ctx, o, fetch)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if !page.HasMore {
+				return
+			}
+			o.Offset += len(page.Items)
+		}
+	}
+}
+
+// collect drains a paginated iterator into a slice.
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var items []T
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
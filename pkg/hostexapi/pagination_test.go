@@ -0,0 +1,97 @@
+package hostexapi
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func fakeFetcher(items []int, reportTotal bool) pageFetcher[int] {
+	return func(_ context.Context, opts ListOptions) ([]int, int, error) {
+		total := -1
+		if reportTotal {
+			total = len(items)
+		}
+		start := min(opts.Offset, len(items))
+		end := min(opts.Offset+opts.Limit, len(items))
+		return items[start:end], total, nil
+	}
+}
+
+func makeItems(n int) []int {
+	items := make([]int, n)
+	for i := range items {
+		items[i] = i
+	}
+	return items
+}
+
+func TestFetchPageHasMore(t *testing.T) {
+	tests := []struct {
+		name        string
+		itemCount   int
+		offset      int
+		limit       int
+		reportTotal bool
+		wantItems   int
+		wantHasMore bool
+	}{
+		{"without total, full page", 10, 0, 5, false, 5, true},
+		{"without total, partial page", 7, 5, 5, false, 2, false},
+		{"without total, exactly full last page", 10, 5, 5, false, 5, true},
+		{"without total, empty page", 10, 10, 5, false, 0, false},
+		{"with total, more remaining", 10, 0, 5, true, 5, true},
+		{"with total, exactly full last page", 10, 5, 5, true, 5, false},
+		{"with total, past the end", 10, 20, 5, true, 0, false},
+		{"limit defaults when unset", 60, 0, 0, true, DefaultPageSize, true},
+		{"limit capped at maximum", 150, 0, 500, true, MaxPageSize, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := fetchPage(context.Background(), ListOptions{Offset: tt.offset, Limit: tt.limit}, fakeFetcher(makeItems(tt.itemCount), tt.reportTotal))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(page.Items) != tt.wantItems {
+				t.Errorf("got %d items, want %d", len(page.Items), tt.wantItems)
+			}
+			if page.HasMore != tt.wantHasMore {
+				t.Errorf("got HasMore %v, want %v", page.HasMore, tt.wantHasMore)
+			}
+		})
+	}
+}
+
+func TestPaginateCanBeReused(t *testing.T) {
+	items := makeItems(23)
+	seq := paginate(context.Background(), ListOptions{Limit: 5}, fakeFetcher(items, false))
+	for i := range 2 {
+		got, err := collect(seq)
+		if err != nil {
+			t.Fatalf("iteration %d: unexpected error: %v", i, err)
+		}
+		if !slices.Equal(got, items) {
+			t.Fatalf("iteration %d: got %v, want %v", i, got, items)
+		}
+	}
+}
+
+func TestPaginateConcurrent(t *testing.T) {
+	items := makeItems(40)
+	seq := paginate(context.Background(), ListOptions{Limit: 3}, fakeFetcher(items, true))
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := collect(seq)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if !slices.Equal(got, items) {
+				t.Errorf("got %v, want %v", got, items)
+			}
+		}()
+	}
+	wg.Wait()
+}
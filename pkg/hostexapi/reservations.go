@@ -0,0 +1,178 @@
+package hostexapi
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Reservation statuses reported by Hostex.
+const (
+	ReservationStatusWaitAccept = "wait_accept"
+	ReservationStatusWaitPay    = "wait_pay"
+	ReservationStatusAccepted   = "accepted"
+	ReservationStatusCancelled  = "cancelled"
+	ReservationStatusDenied     = "denied"
+	ReservationStatusTimeout    = "timeout"
+)
+
+// Money is an amount in a currency.
+type Money struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+func (m Money) String() string {
+	return strconv.FormatFloat(m.Amount, 'f', 2, 64) + " " + m.Currency
+}
+
+// ReservationRates is the price breakdown of a reservation.
+type ReservationRates struct {
+	TotalRate       Money `json:"total_rate"`
+	TotalCommission Money `json:"total_commission"`
+	Rate            Money `json:"rate"`
+	Commission      Money `json:"commission"`
+}
+
+type Reservation struct {
+	ReservationCode string `json:"reservation_code"`
+	StayCode        string `json:"stay_code"`
+	ChannelID       string `json:"channel_id"`
+	PropertyID      int    `json:"property_id"`
+	ListingID       string `json:"listing_id"`
+	ChannelType     string `json:"channel_type"`
+
+	GuestName  string `json:"guest_name"`
+	GuestEmail string `json:"guest_email"`
+	GuestPhone string `json:"guest_phone"`
+
+	CheckInDate      string `json:"check_in_date"`
+	CheckOutDate     string `json:"check_out_date"`
+	NumberOfGuests   int    `json:"number_of_guests"`
+	NumberOfAdults   int    `json:"number_of_adults"`
+	NumberOfChildren int    `json:"number_of_children"`
+	NumberOfInfants  int    `json:"number_of_infants"`
+	NumberOfPets     int    `json:"number_of_pets"`
+
+	Status string           `json:"status"`
+	Rates  ReservationRates `json:"rates"`
+	// Remarks are the host's internal notes about the reservation
+	Remarks string   `json:"remarks"`
+	Tags    []string `json:"tags"`
+	Creator string   `json:"creator"`
+
+	BookedAt    time.Time `json:"booked_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	CancelledAt time.Time `json:"cancelled_at"`
+
+	ConversationID string `json:"conversation_id"`
+}
+
+// Nights returns the length of the stay, or 0 if the dates can't be parsed.
+func (r *Reservation) Nights() int {
+	checkIn, err := time.Parse(time.DateOnly, r.CheckInDate)
+	if err != nil {
+		return 0
+	}
+	checkOut, err := time.Parse(time.DateOnly, r.CheckOutDate)
+	if err != nil {
+		return 0
+	}
+	return int(checkOut.Sub(checkIn).Hours() / 24)
+}
+
+type ReservationsResponse struct {
+	Reservations []Reservation `json:"reservations"`
+}
+
+// ReservationListOptions filters the reservations list. Empty fields and zero dates aren't filtered on.
+// Date ranges are inclusive and only the date part of the times is used.
+type ReservationListOptions struct {
+	ListOptions
+	PropertyID      string
+	ReservationCode string
+	Status          string
+	ChannelType     string
+	CheckInFrom     time.Time
+	CheckInTo       time.Time
+	CheckOutFrom    time.Time
+	CheckOutTo      time.Time
+}
+
+func (o ReservationListOptions) values() url.Values {
+	query := o.ListOptions.values()
+	setIfNotEmpty := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	setDate := func(key string, date time.Time) {
+		if !date.IsZero() {
+			query.Set(key, date.Format(time.DateOnly))
+		}
+	}
+	setIfNotEmpty("property_id", o.PropertyID)
+	setIfNotEmpty("reservation_code", o.ReservationCode)
+	setIfNotEmpty("status", o.Status)
+	setIfNotEmpty("channel_type", o.ChannelType)
+	setDate("start_check_in_date", o.CheckInFrom)
+	setDate("end_check_in_date", o.CheckInTo)
+	setDate("start_check_out_date", o.CheckOutFrom)
+	setDate("end_check_out_date", o.CheckOutTo)
+	return query
+}
+
+func (c *Client) fetchReservations(ctx context.Context, opts ReservationListOptions) ([]Reservation, error) {
+	resp, err := doRequest[ReservationsResponse](ctx, c, "GET", "/reservations?"+opts.values().Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data.Reservations, nil
+}
+
+func (c *Client) reservationFetcher(opts ReservationListOptions) pageFetcher[Reservation] {
+	return func(ctx context.Context, page ListOptions) ([]Reservation, int, error) {
+		opts.ListOptions = page
+		reservations, err := c.fetchReservations(ctx, opts)
+		return reservations, -1, err
+	}
+}
+
+// ListReservations fetches a single page of reservations.
+func (c *Client) ListReservations(ctx context.Context, opts ReservationListOptions) (*Page[Reservation], error) {
+	return fetchPage(ctx, opts.ListOptions, c.reservationFetcher(opts))
+}
+
+// IterateReservations yields every reservation matching opts, fetching pages as needed.
+func (c *Client) IterateReservations(ctx context.Context, opts ReservationListOptions) iter.Seq2[Reservation, error] {
+	return paginate(ctx, opts.ListOptions, c.reservationFetcher(opts))
+}
+
+// GetReservations fetches all reservations, optionally limited to a single property.
+func (c *Client) GetReservations(ctx context.Context, propertyID string) ([]Reservation, error) {
+	return c.FindReservations(ctx, ReservationListOptions{PropertyID: propertyID})
+}
+
+// FindReservations fetches all reservations matching the filters in opts.
+func (c *Client) FindReservations(ctx context.Context, opts ReservationListOptions) ([]Reservation, error) {
+	opts.ListOptions = ListOptions{Limit: MaxPageSize}
+	return collect(c.IterateReservations(ctx, opts))
+}
+
+// GetReservation looks up a single reservation by its code. If there's no such
+// reservation, the returned error matches ErrNotFound.
+func (c *Client) GetReservation(ctx context.Context, reservationCode string) (*Reservation, error) {
+	page, err := c.ListReservations(ctx, ReservationListOptions{ReservationCode: reservationCode})
+	if err != nil {
+		return nil, err
+	}
+	for _, reservation := range page.Items {
+		if reservation.ReservationCode == reservationCode {
+			return &reservation, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: reservation %s", ErrNotFound, reservationCode)
+}
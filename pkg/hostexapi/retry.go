@@ -0,0 +1,68 @@
+package hostexapi
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the number of times a request is tried before giving up
+	DefaultMaxAttempts = 4
+	retryBaseDelay     = 500 * time.Millisecond
+	retryMaxDelay      = 10 * time.Second
+	// Requests aren't retried if the server asks to wait longer than this
+	retryMaxRetryAfter = time.Minute
+)
+
+// isIdempotent reports whether a request with the given method can be repeated safely.
+// POST is used for sending messages and creating resources on Hostex, so it isn't.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// isConnectError reports whether a request failed before anything was sent to the server,
+// which makes it safe to repeat regardless of the method.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// retryBackoff returns the delay before the given retry (starting at 1),
+// using exponential backoff with full jitter.
+func retryBackoff(retry int) time.Duration {
+	delay := retryBaseDelay << (retry - 1)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(delay))) + time.Millisecond
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package hostexapi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "30", 30 * time.Second},
+		{"zero seconds", "0", 0},
+		{"negative seconds", "-5", 0},
+		{"http date in the future", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"http date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"garbage", "soon", 0},
+		{"fractional seconds", "1.5", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header, now); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffBounds(t *testing.T) {
+	for _, retry := range []int{1, 2, 3, 5, 10, 64, 100} {
+		limit := retryMaxDelay
+		if retry < 10 {
+			limit = min(retryBaseDelay<<(retry-1), retryMaxDelay)
+		}
+		for range 200 {
+			delay := retryBackoff(retry)
+			if delay <= 0 || delay > limit+time.Millisecond {
+				t.Fatalf("retry %d: delay %v outside (0, %v]", retry, delay, limit+time.Millisecond)
+			}
+		}
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	tests := map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodPut:     true,
+		http.MethodDelete:  true,
+		http.MethodOptions: true,
+		http.MethodPost:    false,
+		http.MethodPatch:   false,
+	}
+	for method, want := range tests {
+		if got := isIdempotent(method); got != want {
+			t.Errorf("isIdempotent(%s) = %v, want %v", method, got, want)
+		}
+	}
+}
+
+func TestIsConnectError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dial error", &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, true},
+		{"wrapped dial error", fmt.Errorf("request failed: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+		{"dns error", &net.DNSError{Err: "no such host", Name: "api.hostex.io"}, true},
+		{"read error", &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, false},
+		{"other error", errors.New("unexpected EOF"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnectError(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package hostexapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/url"
+	"time"
+)
+
+// ReviewContent is a review or review reply written by the guest or the host.
+type ReviewContent struct {
+	// Score is the overall rating from 1 to 5. It's 0 for replies.
+	Score     float64   `json:"score"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Review contains the reviews the guest and host left each other for a reservation.
+// Fields are nil if that review or reply hasn't been written yet.
+type Review struct {
+	ReservationCode string `json:"reservation_code"`
+	PropertyID      int    `json:"property_id"`
+	ChannelType     string `json:"channel_type"`
+	ListingID       string `json:"listing_id"`
+	CheckInDate     string `json:"check_in_date"`
+	CheckOutDate    string `json:"check_out_date"`
+
+	GuestReview *ReviewContent `json:"guest_review"`
+	HostReview  *ReviewContent `json:"host_review"`
+	// HostReply is the host's public response to the guest review
+	HostReply *ReviewContent `json:"host_reply"`
+}
+
+type ReviewsResponse struct {
+	Reviews []Review `json:"reviews"`
+}
+
+// ReviewListOptions filters the reviews list. Empty fields and zero dates aren't filtered on.
+type ReviewListOptions struct {
+	ListOptions
+	PropertyID      string
+	ReservationCode string
+	CheckOutFrom    time.Time
+	CheckOutTo      time.Time
+}
+
+func (o ReviewListOptions) values() url.Values {
+	query := o.ListOptions.values()
+	if o.PropertyID != "" {
+		query.Set("property_id", o.PropertyID)
+	}
+	if o.ReservationCode != "" {
+		query.Set("reservation_code", o.ReservationCode)
+	}
+	if !o.CheckOutFrom.IsZero() {
+		query.Set("start_check_out_date", o.CheckOutFrom.Format(time.DateOnly))
+	}
+	if !o.CheckOutTo.IsZero() {
+		query.Set("end_check_out_date", o.CheckOutTo.Format(time.DateOnly))
+	}
+	return query
+}
+
+func (c *Client) reviewFetcher(opts ReviewListOptions) pageFetcher[Review] {
+	return func(ctx context.Context, page ListOptions) ([]Review, int, error) {
+		opts.ListOptions = page
+		resp, err := doRequest[ReviewsResponse](ctx, c, "GET", "/reviews?"+opts.values().Encode(), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.Data.Reviews, -1, nil
+	}
+}
+
+// ListReviews fetches a single page of reviews.
+func (c *Client) ListReviews(ctx context.Context, opts ReviewListOptions) (*Page[Review], error) {
+	return fetchPage(ctx, opts.ListOptions, c.reviewFetcher(opts))
+}
+
+// IterateReviews yields every review matching opts, fetching pages as needed.
+func (c *Client) IterateReviews(ctx context.Context, opts ReviewListOptions) iter.Seq2[Review, error] {
+	return paginate(ctx, opts.ListOptions, c.reviewFetcher(opts))
+}
+
+// GetReview fetches the reviews of a single reservation. If there are none,
+// the returned error matches ErrNotFound.
+func (c *Client) GetReview(ctx context.Context, reservationCode string) (*Review, error) {
+	page, err := c.ListReviews(ctx, ReviewListOptions{ReservationCode: reservationCode})
+	if err != nil {
+		return nil, err
+	}
+	for _, review := range page.Items {
+		if review.ReservationCode == reservationCode {
+			return &review, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: review of reservation %s", ErrNotFound, reservationCode)
+}
+
+// ReplyToReview posts the host's public response to the guest review of a reservation.
+func (c *Client) ReplyToReview(ctx context.Context, reservationCode, content string) error {
+	if content == "" {
+		return fmt.Errorf("reply content is empty")
+	}
+	_, err := doRequest[json.RawMessage](ctx, c, "POST", "/reviews/"+url.PathEscape(reservationCode), map[string]interface{}{
+		"host_reply_content": content,
+	})
+	return err
+}
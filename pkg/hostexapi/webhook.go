@@ -0,0 +1,125 @@
+package hostexapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookMessageCreated       WebhookEventType = "message_created"
+	WebhookReservationCreated   WebhookEventType = "reservation_created"
+	WebhookReservationUpdated   WebhookEventType = "reservation_updated"
+	WebhookReservationCancelled WebhookEventType = "reservation_cancelled"
+	WebhookReviewPosted         WebhookEventType = "review_posted"
+)
+
+// WebhookEvent is a single webhook delivery from Hostex.
+// Exactly one of Message, Reservation or Review is set for known event types.
+type WebhookEvent struct {
+	ID        string           `json:"id"`
+	Type      WebhookEventType `json:"event"`
+	Timestamp time.Time        `json:"timestamp"`
+	// Data holds the event-specific payload. Some deliveries put the fields next to
+	// the envelope instead of nesting them, in which case Data is empty.
+	Data json.RawMessage `json:"data,omitempty"`
+
+	Message     *MessageCreatedEvent `json:"-"`
+	Reservation *ReservationEvent    `json:"-"`
+	Review      *ReviewPostedEvent   `json:"-"`
+}
+
+type MessageCreatedEvent struct {
+	ConversationID  string `json:"conversation_id"`
+	MessageID       string `json:"message_id"`
+	ReservationCode string `json:"reservation_code"`
+	SenderRole      string `json:"sender_role"`
+}
+
+type ReservationEvent struct {
+	ReservationCode string `json:"reservation_code"`
+	PropertyID      int    `json:"property_id"`
+	ConversationID  string `json:"conversation_id"`
+	Status          string `json:"status"`
+}
+
+type ReviewPostedEvent struct {
+	ReservationCode string `json:"reservation_code"`
+	PropertyID      int    `json:"property_id"`
+	ConversationID  string `json:"conversation_id"`
+}
+
+// ParseWebhookEvent decodes a webhook request body into a WebhookEvent with the typed payload filled in.
+// Unknown event types are returned without an error so that callers can log and ignore them.
+func ParseWebhookEvent(body []byte) (*WebhookEvent, error) {
+	var evt WebhookEvent
+	if err := json.Unmarshal(body, &evt); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook event: %w", err)
+	}
+	if evt.Type == "" {
+		return nil, fmt.Errorf("webhook event type is missing")
+	}
+
+	payload := []byte(evt.Data)
+	if len(payload) == 0 || string(payload) == "null" {
+		payload = body
+	}
+
+	var target any
+	switch evt.Type {
+	case WebhookMessageCreated:
+		evt.Message = &MessageCreatedEvent{}
+		target = evt.Message
+	case WebhookReservationCreated, WebhookReservationUpdated, WebhookReservationCancelled:
+		evt.Reservation = &ReservationEvent{}
+		target = evt.Reservation
+	case WebhookReviewPosted:
+		evt.Review = &ReviewPostedEvent{}
+		target = evt.Review
+	default:
+		return &evt, nil
+	}
+	if err := json.Unmarshal(payload, target); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s payload: %w", evt.Type, err)
+	}
+	return &evt, nil
+}
+
+// Webhook is a webhook subscription registered on the Hostex account.
+type Webhook struct {
+	ID         int    `json:"id"`
+	URL        string `json:"url"`
+	Manageable bool   `json:"manageable"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// GetWebhooks lists the webhooks registered on the account.
+func (c *Client) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	resp, err := doRequest[WebhooksResponse](ctx, c, "GET", "/webhooks", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data.Webhooks, nil
+}
+
+// CreateWebhook registers a URL that Hostex will deliver events to.
+func (c *Client) CreateWebhook(ctx context.Context, url string) error {
+	_, err := doRequest[json.RawMessage](ctx, c, "POST", "/webhooks", map[string]interface{}{
+		"url": url,
+	})
+	return err
+}
+
+// DeleteWebhook removes a webhook registration.
+func (c *Client) DeleteWebhook(ctx context.Context, webhookID int) error {
+	_, err := doRequest[json.RawMessage](ctx, c, "DELETE", "/webhooks/"+strconv.Itoa(webhookID), nil)
+	return err
+}
@@ -0,0 +1,26 @@
+package hostexdb
+
+import (
+	"hostex-matrix-bridge/pkg/hostexdb/upgrades"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+)
+
+// Database holds the Hostex-specific tables that live next to the bridgev2 tables.
+type Database struct {
+	*dbutil.Database
+	SyncCursor *SyncCursorQuery
+}
+
+func New(bridgeID networkid.BridgeID, db *dbutil.Database, log zerolog.Logger) *Database {
+	db = db.Child("hostex_version", upgrades.Table, dbutil.ZeroLogger(log))
+	return &Database{
+		Database: db,
+		SyncCursor: &SyncCursorQuery{
+			BridgeID:    bridgeID,
+			QueryHelper: dbutil.MakeQueryHelper(db, newSyncCursor),
+		},
+	}
+}
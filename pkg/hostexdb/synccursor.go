@@ -0,0 +1,89 @@
+package hostexdb
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+)
+
+// SyncCursor records how far a conversation has been bridged for a single login,
+// so polling can resume after a restart without duplicating or skipping messages.
+type SyncCursor struct {
+	BridgeID        networkid.BridgeID
+	LoginID         networkid.UserLoginID
+	ConversationID  string
+	LastMessageAt   time.Time
+	LastProcessedAt time.Time
+}
+
+type SyncCursorQuery struct {
+	BridgeID networkid.BridgeID
+	*dbutil.QueryHelper[*SyncCursor]
+}
+
+const (
+	getSyncCursorsForLoginQuery = `
+		SELECT bridge_id, login_id, conversation_id, last_message_at, last_processed_at
+		FROM hostex_sync_cursor
+		WHERE bridge_id=$1 AND login_id=$2
+	`
+	getSyncCursorQuery = `
+		SELECT bridge_id, login_id, conversation_id, last_message_at, last_processed_at
+		FROM hostex_sync_cursor
+		WHERE bridge_id=$1 AND login_id=$2 AND conversation_id=$3
+	`
+	upsertSyncCursorQuery = `
+		INSERT INTO hostex_sync_cursor (bridge_id, login_id, conversation_id, last_message_at, last_processed_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (bridge_id, login_id, conversation_id) DO UPDATE
+			SET last_message_at=excluded.last_message_at, last_processed_at=excluded.last_processed_at
+	`
+)
+
+func newSyncCursor(_ *dbutil.QueryHelper[*SyncCursor]) *SyncCursor {
+	return &SyncCursor{}
+}
+
+func (scq *SyncCursorQuery) GetAllForLogin(ctx context.Context, loginID networkid.UserLoginID) ([]*SyncCursor, error) {
+	return scq.QueryMany(ctx, getSyncCursorsForLoginQuery, scq.BridgeID, loginID)
+}
+
+func (scq *SyncCursorQuery) Get(ctx context.Context, loginID networkid.UserLoginID, conversationID string) (*SyncCursor, error) {
+	return scq.QueryOne(ctx, getSyncCursorQuery, scq.BridgeID, loginID, conversationID)
+}
+
+func (scq *SyncCursorQuery) Put(ctx context.Context, cursor *SyncCursor) error {
+	cursor.BridgeID = scq.BridgeID
+	return scq.Exec(ctx, upsertSyncCursorQuery, cursor.sqlVariables()...)
+}
+
+func (sc *SyncCursor) Scan(row dbutil.Scannable) (*SyncCursor, error) {
+	var lastMessageAt, lastProcessedAt int64
+	err := row.Scan(&sc.BridgeID, &sc.LoginID, &sc.ConversationID, &lastMessageAt, &lastProcessedAt)
+	if err != nil {
+		return nil, err
+	}
+	sc.LastMessageAt = unixNanoToTime(lastMessageAt)
+	sc.LastProcessedAt = unixNanoToTime(lastProcessedAt)
+	return sc, nil
+}
+
+func (sc *SyncCursor) sqlVariables() []any {
+	return []any{sc.BridgeID, sc.LoginID, sc.ConversationID, timeToUnixNano(sc.LastMessageAt), timeToUnixNano(sc.LastProcessedAt)}
+}
+
+func unixNanoToTime(ts int64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ts)
+}
+
+func timeToUnixNano(ts time.Time) int64 {
+	if ts.IsZero() {
+		return 0
+	}
+	return ts.UnixNano()
+}
@@ -0,0 +1,15 @@
+-- v0 -> v1: Latest revision
+
+CREATE TABLE hostex_sync_cursor (
+	bridge_id         TEXT   NOT NULL,
+	login_id          TEXT   NOT NULL,
+	conversation_id   TEXT   NOT NULL,
+	-- last_message_at reported by the conversations list when the conversation was last processed
+	last_message_at   BIGINT NOT NULL,
+	-- created_at of the newest message that was queued to Matrix
+	last_processed_at BIGINT NOT NULL,
+
+	PRIMARY KEY (bridge_id, login_id, conversation_id),
+	CONSTRAINT hostex_sync_cursor_user_login_fkey FOREIGN KEY (bridge_id, login_id)
+		REFERENCES user_login (bridge_id, id) ON DELETE CASCADE ON UPDATE CASCADE
+);